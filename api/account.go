@@ -0,0 +1,277 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"lightRoom/cache"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/utils"
+	"net/http"
+)
+
+// emailChange is what a confirmation link applies once it is followed.
+type emailChange struct {
+	UserID   uuid.UUID `json:"user_id"`
+	NewEmail string    `json:"new_email"`
+}
+
+// checkPassword verifies the signed in user's password under the login throttle, so a stolen
+// session cannot be used to guess it. It writes the error response itself.
+func checkPassword(writer http.ResponseWriter, request *http.Request, user models.User, password, detail string) bool {
+	clientIP := utils.ClientIP(request)
+	if wait := utils.LoginRetryAfter(user.Email, clientIP); wait > 0 {
+		utils.SetRetryAfter(writer, wait)
+		utils.JSONResponse(writer, "too many incorrect passwords, try again later", http.StatusTooManyRequests)
+		return false
+	}
+	if !utils.ComparePasswords(user.Password, password) {
+		wait, locked := utils.RecordLoginFailure(user.Email, clientIP)
+		if locked {
+			go sendLockoutMail(user, clientIP)
+		}
+		if wait > 0 {
+			utils.SetRetryAfter(writer, wait)
+		}
+		utils.JSONResponse(writer, detail, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Account godoc
+// @Tags Auth
+// @Summary Change Password
+// @Description Every other session is signed out, the one making the change stays signed in
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user body schemas.ChangePasswordPayload true "Change Password Payload"
+// @Router /api/v1/auth/change-password [post]
+// @Success  200  {object} schemas.MessagePayload
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+func ChangePassword(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var changePasswordPayload schemas.ChangePasswordPayload
+
+	err := json.Unmarshal(body, &changePasswordPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(changePasswordPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !checkPassword(writer, request, user, changePasswordPayload.OldPassword, "old password is incorrect") {
+		return
+	}
+
+	err = utils.CheckPasswordPolicy(changePasswordPayload.NewPassword, user.Email, user.Name)
+	if err != nil {
+		utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var userUpdate models.User
+	userUpdate.Password, err = utils.HashPassword(changePasswordPayload.NewPassword)
+	if err != nil {
+		utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = models.UpdateUser(user.ID, userUpdate)
+	if err != nil {
+		utils.JSONResponse(writer, "could not change password", http.StatusInternalServerError)
+		return
+	}
+	_ = cache.DeleteOtherUserSessions(user.ID.String(), utils.ContextSessionID(request))
+
+	utils.DSJsonResponse(writer, []byte(`{"message": "password changed"}`), http.StatusOK)
+}
+
+// Account godoc
+// @Tags Auth
+// @Summary Change Email
+// @Description Mails a confirmation link to the new address and a notice to the current one, the email changes once the link is followed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user body schemas.ChangeEmailPayload true "Change Email Payload"
+// @Router /api/v1/auth/change-email [post]
+// @Success  200  {object} schemas.MessagePayload
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+func RequestEmailChange(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var changeEmailPayload schemas.ChangeEmailPayload
+
+	err := json.Unmarshal(body, &changeEmailPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(changeEmailPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !checkPassword(writer, request, user, changeEmailPayload.Password, "password is incorrect") {
+		return
+	}
+	if utils.NormalizeEmail(changeEmailPayload.NewEmail) == utils.NormalizeEmail(user.Email) {
+		utils.JSONResponse(writer, "this is already your email", http.StatusBadRequest)
+		return
+	}
+	if _, err = models.FetchViaMail(changeEmailPayload.NewEmail); err == nil {
+		utils.JSONResponse(writer, "email is already in use", http.StatusBadRequest)
+		return
+	}
+
+	token := utils.TokenGenerator()
+	change, _ := json.Marshal(emailChange{UserID: user.ID, NewEmail: changeEmailPayload.NewEmail})
+	err = cache.SetEmailChange(utils.HashToken(token), change, utils.Settings.VerificationTokenExpiry)
+	if err != nil {
+		utils.JSONResponse(writer, "could not start email change", http.StatusInternalServerError)
+		return
+	}
+
+	confirmData := struct {
+		Name      string
+		Link      string
+		ExpiresIn string
+	}{
+		Name:      user.Name,
+		Link:      utils.FrontendLink("confirm-email", token),
+		ExpiresIn: utils.Settings.VerificationTokenExpiry.String(),
+	}
+	utils.SendTemplateMail([]string{changeEmailPayload.NewEmail}, "Confirm your new LightRoom email", "templates/email_change_email.html", confirmData)
+
+	noticeData := struct {
+		Name     string
+		NewEmail string
+	}{
+		Name:     user.Name,
+		NewEmail: changeEmailPayload.NewEmail,
+	}
+	utils.SendTemplateMail([]string{user.Email}, "Your LightRoom email is being changed", "templates/email_change_notice_email.html", noticeData)
+
+	utils.DSJsonResponse(writer, []byte(`{"message": "confirmation sent to the new email"}`), http.StatusOK)
+}
+
+// Account godoc
+// @Tags Auth
+// @Summary Confirm Email Change
+// @Accept json
+// @Produce json
+// @Param user body schemas.TokenPayload true "Token Payload"
+// @Router /api/v1/auth/change-email/confirm [post]
+// @Success  200  {object} schemas.MessagePayload
+// @Failure      400  {object} schemas.ErrorPayload
+func ConfirmEmailChange(writer http.ResponseWriter, request *http.Request) {
+	body, _ := ioutil.ReadAll(request.Body)
+	var tokenPayload schemas.TokenPayload
+
+	err := json.Unmarshal(body, &tokenPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "token body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(tokenPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changeJson, err := cache.TakeEmailChange(utils.HashToken(tokenPayload.Token))
+	if err != nil {
+		utils.JSONResponse(writer, "token has expired/not found", http.StatusBadRequest)
+		return
+	}
+	var change emailChange
+	if err = json.Unmarshal(changeJson, &change); err != nil {
+		utils.JSONResponse(writer, "token has expired/not found", http.StatusBadRequest)
+		return
+	}
+
+	// The address may have been taken since the change was asked for.
+	if _, err = models.FetchViaMail(change.NewEmail); err == nil {
+		utils.JSONResponse(writer, "email is already in use", http.StatusBadRequest)
+		return
+	}
+
+	err = models.UpdateUser(change.UserID, models.User{Email: change.NewEmail})
+	if err != nil {
+		utils.JSONResponse(writer, "could not change email", http.StatusInternalServerError)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{"message": "email changed"}`), http.StatusOK)
+}
+
+// Users godoc
+// @Tags Users
+// @Summary Update Me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user body schemas.UserUpdatePayload true "User Update Payload"
+// @Router /api/v1/users/me [patch]
+// @Success  200  {object} models.User
+// @Failure      400  {object} schemas.ErrorPayload
+func UpdateMe(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var userUpdatePayload schemas.UserUpdatePayload
+
+	err := json.Unmarshal(body, &userUpdatePayload)
+	if err != nil {
+		utils.JSONResponse(writer, "user body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(userUpdatePayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var userUpdate models.User
+	if userUpdatePayload.Name != nil {
+		userUpdate.Name = *userUpdatePayload.Name
+	}
+	err = models.UpdateUser(user.ID, userUpdate)
+	if err != nil {
+		utils.JSONResponse(writer, "could not update user", http.StatusInternalServerError)
+		return
+	}
+
+	user, _ = models.GetUser(user.ID)
+	userJson, _ := json.Marshal(user)
+	utils.DSJsonResponse(writer, userJson, http.StatusOK)
+}
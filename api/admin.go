@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"io/ioutil"
+	"lightRoom/cache"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/utils"
+	"net/http"
+	"strings"
+)
+
+// Admin godoc
+// @Tags Admin
+// @Summary List Users
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size"
+// @Param offset query int false "Page offset"
+// @Router /api/v1/admin/users [get]
+// @Success  200  {object}  []models.User
+// @Failure      403  {object} schemas.ErrorPayload
+func AdminGetUsers(writer http.ResponseWriter, request *http.Request) {
+	limit, offset := paginationParams(request)
+
+	users, err := models.GetUsers(limit, offset)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch users", http.StatusInternalServerError)
+		return
+	}
+
+	usersJson, _ := json.Marshal(users)
+	utils.DSJsonResponse(writer, usersJson, http.StatusOK)
+}
+
+// setSuspension is shared by the suspend and unsuspend handlers.
+func setSuspension(writer http.ResponseWriter, request *http.Request, suspended bool) {
+	userID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "user not found", http.StatusNotFound)
+		return
+	}
+
+	callerID, _ := utils.ContextUserID(request)
+	if callerID == userID {
+		utils.JSONResponse(writer, "you cannot change your own suspension", http.StatusBadRequest)
+		return
+	}
+
+	err = models.SetUserSuspended(userID, suspended)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JSONResponse(writer, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.JSONResponse(writer, "user suspension error", http.StatusInternalServerError)
+		return
+	}
+
+	if suspended {
+		err = cache.SetUserSuspended(userID)
+	} else {
+		err = cache.UnsetUserSuspended(userID)
+	}
+	if err != nil {
+		utils.JSONResponse(writer, "user suspension error", http.StatusInternalServerError)
+		return
+	}
+
+	user, _ := models.GetUser(userID)
+	userJson, _ := json.Marshal(user)
+	utils.DSJsonResponse(writer, userJson, http.StatusOK)
+}
+
+// Admin godoc
+// @Tags Admin
+// @Summary Suspend User
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Router /api/v1/admin/users/{id}/suspend [post]
+// @Success  200  {object}  models.User
+// @Failure      404  {object} schemas.ErrorPayload
+func AdminSuspendUser(writer http.ResponseWriter, request *http.Request) {
+	setSuspension(writer, request, true)
+}
+
+// Admin godoc
+// @Tags Admin
+// @Summary Unsuspend User
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Router /api/v1/admin/users/{id}/unsuspend [post]
+// @Success  200  {object}  models.User
+// @Failure      404  {object} schemas.ErrorPayload
+func AdminUnsuspendUser(writer http.ResponseWriter, request *http.Request) {
+	setSuspension(writer, request, false)
+}
+
+// Admin godoc
+// @Tags Admin
+// @Summary Change User Role
+// @Description Signs the user out everywhere and revokes their API keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param role body schemas.RolePayload true "Role Payload"
+// @Router /api/v1/admin/users/{id}/role [patch]
+// @Success  200  {object}  models.User
+// @Failure      400  {object} schemas.ErrorPayload
+func AdminSetUserRole(writer http.ResponseWriter, request *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "user not found", http.StatusNotFound)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var rolePayload schemas.RolePayload
+
+	err = json.Unmarshal(body, &rolePayload)
+	if err != nil {
+		utils.JSONResponse(writer, "role body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(rolePayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = models.SetUserRole(userID, models.Role(rolePayload.Role))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JSONResponse(writer, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.JSONResponse(writer, "user role update error", http.StatusInternalServerError)
+		return
+	}
+
+	// sessions and keys were issued under the old role, so they go with it
+	err = cache.DeleteUserSessions(userID.String())
+	if err != nil {
+		utils.JSONResponse(writer, "user role update error", http.StatusInternalServerError)
+		return
+	}
+	err = models.DeleteUserAPIKeys(userID)
+	if err != nil {
+		utils.JSONResponse(writer, "user role update error", http.StatusInternalServerError)
+		return
+	}
+
+	user, _ := models.GetUser(userID)
+	userJson, _ := json.Marshal(user)
+	utils.DSJsonResponse(writer, userJson, http.StatusOK)
+}
+
+// Admin godoc
+// @Tags Admin
+// @Summary Rename Tag
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Tag ID"
+// @Param tag body schemas.TagPayload true "Tag Payload"
+// @Router /api/v1/admin/tags/{id} [patch]
+// @Success  200  {object}  models.Tag
+// @Failure      400  {object} schemas.ErrorPayload
+func AdminUpdateTag(writer http.ResponseWriter, request *http.Request) {
+	tagID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "tag not found", http.StatusNotFound)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var tagPayload schemas.TagPayload
+
+	err = json.Unmarshal(body, &tagPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "tag body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	tagPayload.Title = strings.TrimSpace(tagPayload.Title)
+	err = validate.Struct(tagPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existingTag, err := models.GetTagByTitle(tagPayload.Title)
+	if err == nil && existingTag.ID != tagID {
+		utils.JSONResponse(writer, "tag already exist", http.StatusBadRequest)
+		return
+	}
+
+	err = models.UpdateTag(tagID, tagPayload.Title)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JSONResponse(writer, "tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.JSONResponse(writer, "tag update error", http.StatusInternalServerError)
+		return
+	}
+
+	tag, _ := models.GetTag(tagID)
+	tagJson, _ := json.Marshal(tag)
+	utils.DSJsonResponse(writer, tagJson, http.StatusOK)
+}
+
+// Admin godoc
+// @Tags Admin
+// @Summary Delete Tag
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Tag ID"
+// @Router /api/v1/admin/tags/{id} [delete]
+// @Success 200 {object} map[string]interface{}
+// @Failure      404  {object} schemas.ErrorPayload
+func AdminDeleteTag(writer http.ResponseWriter, request *http.Request) {
+	tagID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "tag not found", http.StatusNotFound)
+		return
+	}
+
+	err = models.DeleteTag(tagID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JSONResponse(writer, "tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.JSONResponse(writer, "tag delete error", http.StatusInternalServerError)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+}
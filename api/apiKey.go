@@ -0,0 +1,197 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/utils"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	// defaultAPIKeyExpiryDays applies when a key is created without an expiry.
+	defaultAPIKeyExpiryDays = 90
+	maxAPIKeys              = 20
+	// apiKeyUseResolution keeps busy scripts from writing last_used_at on every request.
+	apiKeyUseResolution = time.Minute
+)
+
+// ResolveAPIKey backs utils.APIKeyTicator, it finds the key by its prefix and compares hashes.
+func ResolveAPIKey(rawKey string) (utils.APIKeyIdentity, error) {
+	prefix, ok := utils.APIKeyPrefix(rawKey)
+	if !ok {
+		return utils.APIKeyIdentity{}, utils.ErrInvalidAPIKey
+	}
+	apiKey, err := models.GetAPIKeyByPrefix(prefix)
+	if err != nil || apiKey.User == nil {
+		return utils.APIKeyIdentity{}, utils.ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(rawKey)), []byte(apiKey.KeyHash)) != 1 {
+		return utils.APIKeyIdentity{}, utils.ErrInvalidAPIKey
+	}
+	if time.Now().After(apiKey.ExpiresAt) || apiKey.User.IsSuspended {
+		return utils.APIKeyIdentity{}, utils.ErrInvalidAPIKey
+	}
+
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > apiKeyUseResolution {
+		_ = models.UpdateAPIKeyLastUsed(apiKey.ID)
+	}
+
+	// A key only carries admin rights when it was granted them and its owner still holds them.
+	role := models.RoleUser
+	scopes := make([]string, len(apiKey.Scopes))
+	for index, scope := range apiKey.Scopes {
+		scopes[index] = string(scope)
+		if scope == models.ScopeAdmin && apiKey.User.Role == models.RoleAdmin {
+			role = models.RoleAdmin
+		}
+	}
+	return utils.APIKeyIdentity{
+		KeyID:  apiKey.ID.String(),
+		UserID: apiKey.UserID.String(),
+		Role:   string(role),
+		Scopes: scopes,
+	}, nil
+}
+
+// API Keys godoc
+// @Tags Auth
+// @Summary List API Keys
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/auth/api-keys [get]
+// @Success  200  {object} []models.APIKey
+// @Failure      400  {object} schemas.ErrorPayload
+func GetAPIKeys(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	apiKeys, err := models.GetUserAPIKeys(userID)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch api keys", http.StatusInternalServerError)
+		return
+	}
+
+	apiKeysJson, _ := json.Marshal(apiKeys)
+	utils.DSJsonResponse(writer, apiKeysJson, http.StatusOK)
+}
+
+// API Keys godoc
+// @Tags Auth
+// @Summary Create API Key
+// @Description The key is returned once and cannot be shown again. Send it in the X-API-Key header. Only admins may grant the admin scope.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param apiKey body schemas.CreateAPIKeyPayload true "Create API Key Payload"
+// @Router /api/v1/auth/api-keys [post]
+// @Success  201  {object} schemas.APIKeyCreatedPayload
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      403  {object} schemas.ErrorPayload
+func CreateAPIKey(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var apiKeyPayload schemas.CreateAPIKeyPayload
+
+	err = json.Unmarshal(body, &apiKeyPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "api key body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(apiKeyPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if models.CountUserAPIKeys(userID) >= maxAPIKeys {
+		utils.JSONResponse(writer, "api key limit reached, revoke an unused key first", http.StatusBadRequest)
+		return
+	}
+
+	expiresInDays := apiKeyPayload.ExpiresInDays
+	if expiresInDays == 0 {
+		expiresInDays = defaultAPIKeyExpiryDays
+	}
+	var scopes []models.APIKeyScope
+	for _, scope := range apiKeyPayload.Scopes {
+		scopes = append(scopes, models.APIKeyScope(scope))
+	}
+	if slices.Contains(scopes, models.ScopeAdmin) {
+		user, err := models.GetUser(userID)
+		if err != nil || user.Role != models.RoleAdmin {
+			utils.JSONResponse(writer, "only admins can grant the admin scope", http.StatusForbidden)
+			return
+		}
+	}
+
+	prefix, rawKey := utils.GenerateAPIKey()
+	apiKey := models.APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      apiKeyPayload.Name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashToken(rawKey),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, expiresInDays),
+	}
+	err = models.CreateAPIKey(apiKey)
+	if err != nil {
+		utils.JSONResponse(writer, "could not create api key", http.StatusInternalServerError)
+		return
+	}
+
+	apiKeyJson, _ := json.Marshal(schemas.APIKeyCreatedPayload{Key: rawKey, APIKey: apiKey})
+	utils.DSJsonResponse(writer, apiKeyJson, http.StatusCreated)
+}
+
+// API Keys godoc
+// @Tags Auth
+// @Summary Revoke API Key
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "API Key ID"
+// @Router /api/v1/auth/api-keys/{id} [delete]
+// @Success 200 {object} map[string]interface{}
+// @Failure      404  {object} schemas.ErrorPayload
+func DeleteAPIKey(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	apiKeyID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "api key not found", http.StatusNotFound)
+		return
+	}
+
+	deleted, err := models.DeleteUserAPIKey(userID, apiKeyID)
+	if err != nil {
+		utils.JSONResponse(writer, "could not revoke api key", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		utils.JSONResponse(writer, "api key not found", http.StatusNotFound)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+}
@@ -1,11 +1,10 @@
 package api
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
-	"html/template"
 	"io/ioutil"
 	"lightRoom/cache"
 	"lightRoom/models"
@@ -13,6 +12,8 @@ import (
 	"lightRoom/utils"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
 var validate *validator.Validate
@@ -64,7 +65,17 @@ func CreateUser(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	userPayload.Password, _ = utils.HashPassword(userPayload.Password)
+	err = utils.CheckPasswordPolicy(userPayload.Password, userPayload.Email, userPayload.Name)
+	if err != nil {
+		utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userPayload.Password, err = utils.HashPassword(userPayload.Password)
+	if err != nil {
+		utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	user := models.User{
 		ID:         uuid.New(),
@@ -72,6 +83,7 @@ func CreateUser(writer http.ResponseWriter, request *http.Request) {
 		Email:      userPayload.Email,
 		Password:   userPayload.Password,
 		IsVerified: false,
+		Role:       models.RoleUser,
 	}
 
 	err = models.CreateUser(user)
@@ -85,32 +97,7 @@ func CreateUser(writer http.ResponseWriter, request *http.Request) {
 	}
 
 	//We need to send email with with a token for verification
-	//	pluging in redis to store token here
-	token := utils.TokenGenerator()
-	cache.SetUserVerificationToken(user.ID, token)
-
-	//Send Email Template
-	// Parse the email template
-	verificationTemplate, _ := template.ParseFiles("templates/verification_email.html")
-
-	// Create a data structure to pass to the template
-	data := struct {
-		Name  string
-		Token string
-	}{
-		Name:  user.Name,
-		Token: token,
-	}
-
-	// Execute the template and store the result in a buffer
-	var payload bytes.Buffer
-
-	err = verificationTemplate.Execute(&payload, data)
-	if err == nil {
-
-		utils.SendMail([]string{user.Email}, payload)
-
-	}
+	sendVerificationMail(user)
 
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusCreated)
@@ -126,7 +113,10 @@ func CreateUser(writer http.ResponseWriter, request *http.Request) {
 // @Param user body schemas.LoginPayload true "Login Payload"
 // @Router /api/v1/auth/login [post]
 // @Success  200  {object}  schemas.AccessPayload
+// @Success  202  {object}  schemas.MfaChallengePayload
 // @Failure      400  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+// @Header       429  {integer} Retry-After "Seconds until another attempt is accepted"
 func Login(writer http.ResponseWriter, request *http.Request) {
 	body, _ := ioutil.ReadAll(request.Body)
 	var err error
@@ -151,20 +141,30 @@ func Login(writer http.ResponseWriter, request *http.Request) {
 
 	}
 
+	// Checked before the password so a locked out client cannot keep bcrypt busy.
+	clientIP := utils.ClientIP(request)
+	if wait := utils.LoginRetryAfter(loginPayload.Email, clientIP); wait > 0 {
+		utils.SetRetryAfter(writer, wait)
+		utils.JSONResponse(writer, "too many failed login attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
 	user, err := models.FetchViaMail(loginPayload.Email)
 
 	if err != nil {
-		writer.Header().Set("Content-Type", "application/json")
-		writer.WriteHeader(http.StatusNotFound)
-		writer.Write([]byte(`{"detail": "user email/password is incorrect"}`))
+		rejectLogin(writer, nil, loginPayload.Email, clientIP)
 		return
 	}
 	if utils.ComparePasswords(user.Password, loginPayload.Password) == false {
-		writer.Header().Set("Content-Type", "application/json")
-		writer.WriteHeader(http.StatusNotFound)
-		writer.Write([]byte(`{"detail": "user email/password is incorrect"}`))
+		rejectLogin(writer, &user, loginPayload.Email, clientIP)
 		return
 	}
+	// The password is at hand only now, so this is where old hashes are brought up to date.
+	if utils.NeedsRehash(user.Password) {
+		if rehashed, err := utils.HashPassword(loginPayload.Password); err == nil {
+			_ = models.UpdateUser(user.ID, models.User{Password: rehashed})
+		}
+	}
 	if user.IsVerified == false {
 		writer.Header().Set("Content-Type", "application/json")
 		writer.WriteHeader(http.StatusBadRequest)
@@ -172,13 +172,96 @@ func Login(writer http.ResponseWriter, request *http.Request) {
 		return
 
 	}
-	accessToken := utils.GenerateAccessToken(user.ID)
-	refreshToken := utils.GenerateRefreshToken(user.ID)
-	jsonResponse, _ := json.Marshal(map[string]string{"access_token": accessToken, "refresh_token": refreshToken, "account_verified": "verified"})
+	completeLogin(writer, request, user)
+}
+
+// sendVerificationMail mails a new account verification link to the user.
+func sendVerificationMail(user models.User) {
+	token := utils.TokenGenerator()
+	err := cache.SetUserVerificationToken(user.ID, utils.HashToken(token), utils.Settings.VerificationTokenExpiry)
+	if err != nil {
+		log.Printf("Unable to store verification token for %v: %v", user.ID, err)
+		return
+	}
+
+	data := struct {
+		Name      string
+		Link      string
+		ExpiresIn string
+	}{
+		Name:      user.Name,
+		Link:      utils.FrontendLink("verify-account", token),
+		ExpiresIn: utils.Settings.VerificationTokenExpiry.String(),
+	}
+	utils.SendTemplateMail([]string{user.Email}, "Verify your LightRoom account", "templates/verification_email.html", data)
+}
+
+// rejectLogin counts a wrong email or password towards the backoff and lockout, telling the
+// owner when their account gets locked. Unknown emails are counted too so both fail alike.
+func rejectLogin(writer http.ResponseWriter, user *models.User, email, clientIP string) {
+	wait, locked := utils.RecordLoginFailure(email, clientIP)
+	if locked && user != nil {
+		go sendLockoutMail(*user, clientIP)
+	}
+	if wait > 0 {
+		utils.SetRetryAfter(writer, wait)
+	}
 	writer.Header().Set("Content-Type", "application/json")
-	writer.WriteHeader(http.StatusOK)
-	writer.Write(jsonResponse)
+	writer.WriteHeader(http.StatusNotFound)
+	writer.Write([]byte(`{"detail": "user email/password is incorrect"}`))
+}
+
+func sendLockoutMail(user models.User, clientIP string) {
+	data := struct {
+		Name     string
+		Attempts int64
+		Duration string
+		IP       string
+	}{
+		Name:     user.Name,
+		Attempts: utils.Settings.LoginLockoutAttempts,
+		Duration: utils.Settings.LoginLockoutDuration.String(),
+		IP:       clientIP,
+	}
+	utils.SendTemplateMail([]string{user.Email}, "Your LightRoom account has been locked", "templates/account_locked_email.html", data)
+}
+
+// completeLogin finishes a sign-in whose first factor has been checked. Users with
+// two-factor enabled get an mfa_required challenge instead of tokens.
+func completeLogin(writer http.ResponseWriter, request *http.Request, user models.User) {
+	if user.IsSuspended {
+		utils.JSONResponse(writer, "user account is suspended", http.StatusForbidden)
+		return
+	}
+	if user.TotpEnabled {
+		mfaToken := utils.SecureToken(32)
+		err := cache.SetMfaChallenge(mfaToken, user.ID, utils.Settings.MfaChallengeExpiry)
+		if err != nil {
+			utils.JSONResponse(writer, "could not start session", http.StatusInternalServerError)
+			return
+		}
+		challengeJson, _ := json.Marshal(schemas.MfaChallengePayload{MfaRequired: true, MfaToken: mfaToken})
+		utils.DSJsonResponse(writer, challengeJson, http.StatusAccepted)
+		return
+	}
+	issueSession(writer, request, user)
+}
 
+// issueSession starts a session for the user and writes its tokens. Failed logins are only
+// forgiven here, once any second factor has been passed too, and signing in calls off a
+// scheduled account deletion.
+func issueSession(writer http.ResponseWriter, request *http.Request, user models.User) {
+	accessToken, refreshToken, err := utils.IssueTokens(request, user.ID, string(user.Role))
+	if err != nil {
+		utils.JSONResponse(writer, "could not start session", http.StatusInternalServerError)
+		return
+	}
+	utils.ClearLoginFailures(user.Email)
+	if user.DeletionScheduledAt != nil {
+		_ = models.CancelUserDeletion(user.ID)
+	}
+	jsonResponse, _ := json.Marshal(map[string]string{"access_token": accessToken, "refresh_token": refreshToken, "account_verified": "verified"})
+	utils.DSJsonResponse(writer, jsonResponse, http.StatusOK)
 }
 
 // Auth godoc
@@ -240,7 +323,7 @@ func Verify(writer http.ResponseWriter, request *http.Request) {
 
 	}
 
-	value, err := cache.GetUserVerificationToken(tokenPayload.Token)
+	value, err := cache.TakeUserVerificationToken(utils.HashToken(tokenPayload.Token))
 
 	if err != nil {
 		writer.Header().Set("Content-Type", "application/json")
@@ -249,23 +332,75 @@ func Verify(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 	parsedUUID, _ := uuid.Parse(value)
-	var updateUser models.User
-	updateUser.IsVerified = true
-	models.UpdateUser(parsedUUID, updateUser)
+	err = models.VerifyUser(parsedUUID)
+	if err != nil {
+		utils.JSONResponse(writer, "could not verify account", http.StatusInternalServerError)
+		return
+	}
 
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusOK)
 	writer.Write([]byte(`{"message": "user account verified", "status": "ok"}`))
 }
 
+// Auth godoc
+// @Tags Auth
+// @Summary Resend Verification Email
+// @Description Mails a new verification link and invalidates the previous one. The answer is the same whether or not the email has an account.
+// @Accept json
+// @Produce json
+// @Param user body schemas.EmailPayload true "Email Payload"
+// @Router /api/v1/auth/resend-verification [post]
+// @Success  200  {object} schemas.MessagePayload
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+func ResendVerification(writer http.ResponseWriter, request *http.Request) {
+	body, _ := ioutil.ReadAll(request.Body)
+	var emailPayload schemas.EmailPayload
+
+	err := json.Unmarshal(body, &emailPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "email not provided", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(emailPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Counted for every address, registered or not, so the limit gives nothing away either.
+	count, retryAfter, err := cache.CountRequest("resend-verification", strings.ToLower(emailPayload.Email), time.Hour)
+	if err != nil {
+		utils.JSONResponse(writer, "could not send verification mail", http.StatusInternalServerError)
+		return
+	}
+	if count > utils.Settings.VerificationResendLimit {
+		utils.SetRetryAfter(writer, retryAfter)
+		utils.JSONResponse(writer, "too many verification mails requested, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	user, err := models.FetchViaMail(emailPayload.Email)
+	if err == nil && !user.IsVerified {
+		// Sent in the background so the response time does not tell registered emails apart.
+		go sendVerificationMail(user)
+	}
+
+	utils.DSJsonResponse(writer, []byte(`{"message": "if the email belongs to an unverified account a new verification mail has been sent"}`), http.StatusOK)
+}
+
 // Auth godoc
 // @Tags Auth
 // @Summary Refresh
 // @Param Refresh header string true "token"
 // @Produce json
 // @Router /api/v1/auth/refresh [post]
-// @Success  200  {object} schemas.AccessTokenPayload
+// @Success  200  {object} schemas.TokenPairPayload
 // @Failure  400  {object} schemas.ErrorPayload
+// @Failure  401  {object} schemas.ErrorPayload
 func Refresh(writer http.ResponseWriter, request *http.Request) {
 	refreshToken := request.Header.Get("Refresh")
 
@@ -284,8 +419,13 @@ func Refresh(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	accessToken, err := utils.VerifyRefreshToken(refreshToken)
+	accessToken, newRefreshToken, err := utils.RotateRefreshToken(request, refreshToken, currentRole)
 
+	if errors.Is(err, utils.ErrRefreshTokenReused) {
+		writer.Header().Set("WWW-Authenticate", "Bearer")
+		utils.JSONResponse(writer, err.Error(), http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
 		jsonResponse, _ := json.Marshal(map[string]string{"detail": err.Error()})
 		writer.Header().Set("Content-Type", "application/json")
@@ -294,13 +434,24 @@ func Refresh(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	jsonResponse, _ := json.Marshal(map[string]string{"access_token": accessToken})
-	log.Println(jsonResponse)
+	jsonResponse, _ := json.Marshal(map[string]string{"access_token": accessToken, "refresh_token": newRefreshToken})
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusOK)
 	writer.Write(jsonResponse)
 }
 
+// currentRole reads the stored role for a refresh, refusing users who have since been suspended or removed.
+func currentRole(userID uuid.UUID) (string, error) {
+	user, err := models.GetUser(userID)
+	if err != nil {
+		return "", errors.New("user not found")
+	}
+	if user.IsSuspended {
+		return "", errors.New("user account is suspended")
+	}
+	return string(user.Role), nil
+}
+
 // Logout godoc
 // @Tags Auth
 // @Summary Logout
@@ -335,8 +486,12 @@ func LogOut(writer http.ResponseWriter, request *http.Request) {
 		return
 
 	}
-	cache.SetToken(logoutPayload.AccessToken)
-	cache.SetToken(logoutPayload.RefreshToken)
+	cache.SetToken(logoutPayload.AccessToken, utils.Settings.AccessTokenExpiry)
+	cache.SetToken(logoutPayload.RefreshToken, utils.Settings.RefreshTokenExpiry)
+	// Ending the session also stops tokens rotated from these ones.
+	if userID, err := utils.ContextUserID(request); err == nil {
+		_ = cache.DeleteSession(userID.String(), utils.ContextSessionID(request))
+	}
 
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusOK)
@@ -373,7 +528,9 @@ func PasswordReset(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	userId, err := cache.GetPasswordToken(passwordResetPayload.Token)
+	// The token is only looked at until the password is accepted, a rejected password does not use up the link.
+	tokenHash := utils.HashToken(passwordResetPayload.Token)
+	userId, err := cache.GetPasswordToken(tokenHash)
 
 	if err != nil {
 		writer.Header().Set("Content-Type", "application/json")
@@ -381,10 +538,30 @@ func PasswordReset(writer http.ResponseWriter, request *http.Request) {
 		writer.Write([]byte(`{"detail": "reset token has expired/not found"}`))
 		return
 	}
+	parsedUUID, _ := uuid.Parse(userId)
+	user, err := models.GetUser(parsedUUID)
+	if err != nil {
+		utils.JSONResponse(writer, "user not found", http.StatusNotFound)
+		return
+	}
 
+	err = utils.CheckPasswordPolicy(passwordResetPayload.Password, user.Email, user.Name)
+	if err != nil {
+		utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
 	var userUpdate models.User
-	userUpdate.Password, _ = utils.HashPassword(passwordResetPayload.Password)
-	parsedUUID, _ := uuid.Parse(userId)
+	userUpdate.Password, err = utils.HashPassword(passwordResetPayload.Password)
+	if err != nil {
+		utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Taken for real now, whoever takes it first gets to set the password.
+	if takenUserId, err := cache.TakePasswordToken(tokenHash); err != nil || takenUserId != userId {
+		utils.JSONResponse(writer, "reset token has expired/not found", http.StatusBadRequest)
+		return
+	}
 
 	err = models.UpdateUser(parsedUUID, userUpdate)
 
@@ -394,6 +571,8 @@ func PasswordReset(writer http.ResponseWriter, request *http.Request) {
 		writer.Write([]byte(`{"detail": "user not found"}`))
 		return
 	}
+	// Whoever knew the old password is signed out everywhere.
+	_ = cache.DeleteUserSessions(userId)
 
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusOK)
@@ -442,26 +621,22 @@ func ForgotPassword(writer http.ResponseWriter, request *http.Request) {
 	}
 
 	token := utils.TokenGenerator()
-	cache.SetPasswordToken(token, user.ID)
-	verificationTemplate, _ := template.ParseFiles("templates/verification_email.html")
+	err = cache.SetPasswordToken(utils.HashToken(token), user.ID, utils.Settings.PasswordResetTokenExpiry)
+	if err != nil {
+		utils.JSONResponse(writer, "could not send password reset mail", http.StatusInternalServerError)
+		return
+	}
 
-	// Create a data structure to pass to the template
 	data := struct {
-		Name  string
-		Token string
+		Name      string
+		Link      string
+		ExpiresIn string
 	}{
-		Name:  user.Name,
-		Token: token,
-	}
-
-	// Execute the template and store the result in a buffer
-	var payload bytes.Buffer
-
-	err = verificationTemplate.Execute(&payload, data)
-	if err == nil {
-		utils.SendMail([]string{user.Email}, payload)
-
+		Name:      user.Name,
+		Link:      utils.FrontendLink("reset-password", token),
+		ExpiresIn: utils.Settings.PasswordResetTokenExpiry.String(),
 	}
+	utils.SendTemplateMail([]string{user.Email}, "Reset your LightRoom password", "templates/password_reset_email.html", data)
 
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusOK)
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"lightRoom/cache"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// magicLinkSent is the answer to every link request, so it cannot be used to find out who has an account.
+const magicLinkSent = `{"message": "if the email belongs to an account a sign-in link has been sent"}`
+
+func sendMagicLink(user models.User) {
+	token := utils.SecureToken(32)
+	err := cache.SetMagicLinkToken(utils.HashToken(token), user.ID, utils.Settings.MagicLinkExpiry)
+	if err != nil {
+		log.Printf("Unable to store magic link for %v: %v", user.ID, err)
+		return
+	}
+
+	data := struct {
+		Name      string
+		Link      string
+		ExpiresIn string
+	}{
+		Name:      user.Name,
+		Link:      utils.FrontendLink("magic-link", token),
+		ExpiresIn: utils.Settings.MagicLinkExpiry.String(),
+	}
+	utils.SendTemplateMail([]string{user.Email}, "Your LightRoom sign-in link", "templates/magic_link_email.html", data)
+}
+
+// Auth godoc
+// @Tags Auth
+// @Summary Request Magic Link
+// @Description Emails a single-use sign-in link. The answer is the same whether or not the email has an account.
+// @Accept json
+// @Produce json
+// @Param user body schemas.EmailPayload true "Email Payload"
+// @Router /api/v1/auth/magic-link [post]
+// @Success  200  {object} schemas.MessagePayload
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+func RequestMagicLink(writer http.ResponseWriter, request *http.Request) {
+	body, _ := ioutil.ReadAll(request.Body)
+	var emailPayload schemas.EmailPayload
+
+	err := json.Unmarshal(body, &emailPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "email not provided", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(emailPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Counted for every address, registered or not, so the limit gives nothing away either.
+	count, retryAfter, err := cache.CountRequest("magic-link", strings.ToLower(emailPayload.Email), time.Hour)
+	if err != nil {
+		utils.JSONResponse(writer, "could not send sign-in link", http.StatusInternalServerError)
+		return
+	}
+	if count > utils.Settings.MagicLinkHourlyLimit {
+		utils.SetRetryAfter(writer, retryAfter)
+		utils.JSONResponse(writer, "too many sign-in links requested, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	user, err := models.FetchViaMail(emailPayload.Email)
+	if err == nil && !user.IsSuspended {
+		// Sent in the background so the response time does not tell registered emails apart.
+		go sendMagicLink(user)
+	}
+
+	utils.DSJsonResponse(writer, []byte(magicLinkSent), http.StatusOK)
+}
+
+// Auth godoc
+// @Tags Auth
+// @Summary Sign In With Magic Link
+// @Description Exchanges the token from a magic link for a token pair, or for an MFA challenge when two-factor is enabled.
+// @Accept json
+// @Produce json
+// @Param user body schemas.MagicLinkPayload true "Magic Link Payload"
+// @Router /api/v1/auth/magic-link/verify [post]
+// @Success  200  {object} schemas.AccessPayload
+// @Success  202  {object} schemas.MfaChallengePayload
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      401  {object} schemas.ErrorPayload
+func MagicLinkLogin(writer http.ResponseWriter, request *http.Request) {
+	body, _ := ioutil.ReadAll(request.Body)
+	var magicLinkPayload schemas.MagicLinkPayload
+
+	err := json.Unmarshal(body, &magicLinkPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "token body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(magicLinkPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := cache.TakeMagicLinkToken(utils.HashToken(magicLinkPayload.Token))
+	if err != nil {
+		utils.JSONResponse(writer, "sign-in link is invalid or has expired", http.StatusUnauthorized)
+		return
+	}
+	parsedUUID, _ := uuid.Parse(userID)
+	user, err := models.GetUser(parsedUUID)
+	if err != nil {
+		utils.JSONResponse(writer, "sign-in link is invalid or has expired", http.StatusUnauthorized)
+		return
+	}
+
+	// Following the link proves the user reads this mailbox. Whoever signed up with it
+	// may not have owned it, so the password chosen at sign up is discarded.
+	if !user.IsVerified {
+		err = models.ClaimUnverifiedUser(user.ID, utils.UnusablePassword())
+		if err != nil {
+			utils.JSONResponse(writer, "sign-in error", http.StatusInternalServerError)
+			return
+		}
+		user.IsVerified = true
+	}
+	completeLogin(writer, request, user)
+}
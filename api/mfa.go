@@ -0,0 +1,329 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"lightRoom/cache"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/utils"
+	"net/http"
+)
+
+// maxMfaAttempts is how many wrong codes a login challenge survives.
+const maxMfaAttempts = 5
+
+// checkSecondFactor accepts a current TOTP code or consumes an unused recovery code.
+func checkSecondFactor(user models.User, code string) bool {
+	if step, ok := utils.ValidateTotp(user.TotpSecret, code, user.TotpLastStep); ok {
+		used, err := models.UseTotpStep(user.ID, step)
+		return err == nil && used
+	}
+	used, err := models.UseRecoveryCode(user.ID, utils.HashRecoveryCode(code))
+	return err == nil && used
+}
+
+// verifySecondFactor checks the code under the per-user throttle, so guesses are limited across
+// login challenges and the endpoints that ask for a code. It writes the error response itself.
+func verifySecondFactor(writer http.ResponseWriter, user models.User, code string, failStatus int) bool {
+	userID := user.ID.String()
+	if wait := utils.MfaRetryAfter(userID); wait > 0 {
+		utils.SetRetryAfter(writer, wait)
+		utils.JSONResponse(writer, "too many invalid codes, try again later", http.StatusTooManyRequests)
+		return false
+	}
+	if !checkSecondFactor(user, code) {
+		if wait := utils.RecordMfaFailure(userID); wait > 0 {
+			utils.SetRetryAfter(writer, wait)
+		}
+		utils.JSONResponse(writer, "invalid code", failStatus)
+		return false
+	}
+	utils.ClearMfaFailures(userID)
+	return true
+}
+
+// readMfaCode decodes and validates a {"code"} body, writing the error response itself.
+func readMfaCode(writer http.ResponseWriter, request *http.Request) (string, bool) {
+	body, _ := ioutil.ReadAll(request.Body)
+	var codePayload schemas.MfaCodePayload
+
+	err := json.Unmarshal(body, &codePayload)
+	if err != nil {
+		utils.JSONResponse(writer, "code body not valid", http.StatusUnprocessableEntity)
+		return "", false
+	}
+
+	err = validate.Struct(codePayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return codePayload.Code, true
+}
+
+func contextUser(writer http.ResponseWriter, request *http.Request) (models.User, bool) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return models.User{}, false
+	}
+	user, err := models.GetUser(userID)
+	if err != nil {
+		utils.JSONResponse(writer, "user not found", http.StatusNotFound)
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// MFA godoc
+// @Tags MFA
+// @Summary Two Factor Status
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/auth/2fa [get]
+// @Success  200  {object} schemas.MfaStatusPayload
+// @Failure      400  {object} schemas.ErrorPayload
+func GetMfaStatus(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+
+	statusJson, _ := json.Marshal(schemas.MfaStatusPayload{
+		TotpEnabled:            user.TotpEnabled,
+		RecoveryCodesRemaining: models.CountRecoveryCodes(user.ID),
+	})
+	utils.DSJsonResponse(writer, statusJson, http.StatusOK)
+}
+
+// MFA godoc
+// @Tags MFA
+// @Summary Enroll TOTP
+// @Description Creates a secret to add to an authenticator app, it is not active until confirmed
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/auth/2fa/totp/enroll [post]
+// @Success  200  {object} schemas.TotpEnrollmentPayload
+// @Failure      409  {object} schemas.ErrorPayload
+func EnrollTotp(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+	if user.TotpEnabled {
+		utils.JSONResponse(writer, "two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret := utils.GenerateTotpSecret()
+	err := cache.SetTotpEnrollment(user.ID, secret)
+	if err != nil {
+		utils.JSONResponse(writer, "could not start enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	enrollmentJson, _ := json.Marshal(schemas.TotpEnrollmentPayload{
+		Secret:          secret,
+		ProvisioningURI: utils.TotpProvisioningURI(user.Email, secret),
+	})
+	writer.Header().Set("Cache-Control", "no-store")
+	utils.DSJsonResponse(writer, enrollmentJson, http.StatusOK)
+}
+
+// MFA godoc
+// @Tags MFA
+// @Summary Confirm TOTP
+// @Description Enables two-factor once a code from the enrolled secret checks out, the recovery codes are only shown here
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body schemas.MfaCodePayload true "Code from the authenticator app"
+// @Router /api/v1/auth/2fa/totp/confirm [post]
+// @Success  200  {object} schemas.RecoveryCodesPayload
+// @Failure      400  {object} schemas.ErrorPayload
+func ConfirmTotp(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+	code, ok := readMfaCode(writer, request)
+	if !ok {
+		return
+	}
+
+	secret, err := cache.GetTotpEnrollment(user.ID)
+	if err != nil {
+		utils.JSONResponse(writer, "no enrollment in progress, start again", http.StatusBadRequest)
+		return
+	}
+	step, valid := utils.ValidateTotp(secret, code, 0)
+	if !valid {
+		utils.JSONResponse(writer, "invalid code", http.StatusBadRequest)
+		return
+	}
+
+	recoveryCodes := utils.GenerateRecoveryCodes()
+	codeHashes := make([]string, len(recoveryCodes))
+	for index, recoveryCode := range recoveryCodes {
+		codeHashes[index] = utils.HashRecoveryCode(recoveryCode)
+	}
+	err = models.EnableTotp(user.ID, secret, step, codeHashes)
+	if err != nil {
+		utils.JSONResponse(writer, "could not enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	_ = cache.DeleteTotpEnrollment(user.ID)
+
+	codesJson, _ := json.Marshal(schemas.RecoveryCodesPayload{RecoveryCodes: recoveryCodes})
+	writer.Header().Set("Cache-Control", "no-store")
+	utils.DSJsonResponse(writer, codesJson, http.StatusOK)
+}
+
+// MFA godoc
+// @Tags MFA
+// @Summary Disable TOTP
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body schemas.MfaCodePayload true "TOTP or recovery code"
+// @Router /api/v1/auth/2fa/totp/disable [post]
+// @Success 200 {object} map[string]interface{}
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+func DisableTotp(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+	code, ok := readMfaCode(writer, request)
+	if !ok {
+		return
+	}
+	if !user.TotpEnabled {
+		utils.JSONResponse(writer, "two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+	if !verifySecondFactor(writer, user, code, http.StatusBadRequest) {
+		return
+	}
+
+	err := models.DisableTotp(user.ID)
+	if err != nil {
+		utils.JSONResponse(writer, "could not disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+}
+
+// MFA godoc
+// @Tags MFA
+// @Summary Regenerate Recovery Codes
+// @Description Replaces every recovery code, the old ones stop working
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body schemas.MfaCodePayload true "TOTP or recovery code"
+// @Router /api/v1/auth/2fa/recovery-codes [post]
+// @Success  200  {object} schemas.RecoveryCodesPayload
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+func RegenerateRecoveryCodes(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+	code, ok := readMfaCode(writer, request)
+	if !ok {
+		return
+	}
+	if !user.TotpEnabled {
+		utils.JSONResponse(writer, "two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+	if !verifySecondFactor(writer, user, code, http.StatusBadRequest) {
+		return
+	}
+
+	recoveryCodes := utils.GenerateRecoveryCodes()
+	codeHashes := make([]string, len(recoveryCodes))
+	for index, recoveryCode := range recoveryCodes {
+		codeHashes[index] = utils.HashRecoveryCode(recoveryCode)
+	}
+	err := models.ReplaceRecoveryCodes(user.ID, codeHashes)
+	if err != nil {
+		utils.JSONResponse(writer, "could not create recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	codesJson, _ := json.Marshal(schemas.RecoveryCodesPayload{RecoveryCodes: recoveryCodes})
+	writer.Header().Set("Cache-Control", "no-store")
+	utils.DSJsonResponse(writer, codesJson, http.StatusOK)
+}
+
+// MFA godoc
+// @Tags Auth
+// @Summary Login Second Factor
+// @Description Completes a login that answered with mfa_required
+// @Accept json
+// @Produce json
+// @Param mfa body schemas.MfaLoginPayload true "Challenge token and TOTP or recovery code"
+// @Router /api/v1/auth/login/mfa [post]
+// @Success  200  {object}  schemas.AccessPayload
+// @Failure      401  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+func LoginMfa(writer http.ResponseWriter, request *http.Request) {
+	body, _ := ioutil.ReadAll(request.Body)
+	var mfaLoginPayload schemas.MfaLoginPayload
+
+	err := json.Unmarshal(body, &mfaLoginPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "mfa body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(mfaLoginPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := cache.GetMfaChallenge(mfaLoginPayload.MfaToken)
+	if err != nil {
+		utils.JSONResponse(writer, "login challenge has expired, sign in again", http.StatusUnauthorized)
+		return
+	}
+	parsedUUID, _ := uuid.Parse(userID)
+	user, err := models.GetUser(parsedUUID)
+	if err != nil {
+		utils.JSONResponse(writer, "login challenge has expired, sign in again", http.StatusUnauthorized)
+		return
+	}
+
+	// Two-factor may have been turned off since the password was checked.
+	if !user.TotpEnabled {
+		_ = cache.DeleteMfaChallenge(mfaLoginPayload.MfaToken)
+		utils.JSONResponse(writer, "login challenge has expired, sign in again", http.StatusUnauthorized)
+		return
+	}
+
+	if !verifySecondFactor(writer, user, mfaLoginPayload.Code, http.StatusUnauthorized) {
+		// The challenge is thrown away after a few wrong codes so it cannot be brute forced.
+		attempts, _ := cache.IncrMfaChallengeAttempts(mfaLoginPayload.MfaToken, utils.Settings.MfaChallengeExpiry)
+		if attempts >= maxMfaAttempts {
+			_ = cache.DeleteMfaChallenge(mfaLoginPayload.MfaToken)
+		}
+		return
+	}
+	_ = cache.DeleteMfaChallenge(mfaLoginPayload.MfaToken)
+
+	if user.IsSuspended {
+		utils.JSONResponse(writer, "user account is suspended", http.StatusForbidden)
+		return
+	}
+	issueSession(writer, request, user)
+}
@@ -1,37 +1,179 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"github.com/go-playground/validator/v10"
-	"io/ioutil"
-	"lightRoom/schemas"
+	"errors"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"io"
+	"lightRoom/models"
+	"lightRoom/renditions"
+	"lightRoom/storage"
 	"lightRoom/utils"
+	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"strconv"
 )
 
 const maxUploadFile = 10 << 20
 
+// inspectUpload hashes the file and sniffs its content type from the leading bytes,
+// rewinding it afterwards so it can be uploaded.
+func inspectUpload(file multipart.File) (string, string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+
+	sniffBuffer := make([]byte, 512)
+	read, err := io.ReadFull(file, sniffBuffer)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", "", err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(sniffBuffer[:read]))
+	return hex.EncodeToString(hasher.Sum(nil)), contentType, nil
+}
+
+// storeUpload stores one uploaded file and records it as an asset, with its watermarked preview
+// or renditions. The file is closed before it returns. It writes the error response itself and
+// returns false when the upload should stop.
+func storeUpload(writer http.ResponseWriter, request *http.Request, userID uuid.UUID, uploadFilePath string, isPrivate bool, fileHeader *multipart.FileHeader) (models.Asset, bool) {
+	file, err := fileHeader.Open()
+
+	if err != nil {
+		utils.JSONResponse(writer, "could not open file", http.StatusBadRequest)
+		return models.Asset{}, false
+	}
+	defer file.Close()
+	contentHash, contentType, err := inspectUpload(file)
+	if err != nil {
+		utils.JSONResponse(writer, "could not read file", http.StatusBadRequest)
+		return models.Asset{}, false
+	}
+	if isPrivate && !renditions.Supported(contentType) {
+		utils.JSONResponse(writer, "paywalled files must be JPEG, PNG or WebP images", http.StatusBadRequest)
+		return models.Asset{}, false
+	}
+
+	// The same bytes uploaded again by the same user as the same file type resolve to the asset they already have.
+	existingAsset, err := models.GetUserAssetByHash(userID, uploadFilePath, contentHash)
+	if err == nil {
+		return existingAsset, true
+	}
+
+	uploadKey := storage.ObjectKey(uploadFilePath, userID, contentHash, contentType)
+	if isPrivate {
+		uploadKey = storage.PrivateObjectKey(uploadFilePath, userID, contentHash, contentType)
+	}
+	err = storage.Store.Put(request.Context(), uploadKey, file, fileHeader.Size, contentType)
+
+	if err != nil {
+		log.Printf("Unable to upload file %v", err)
+		utils.JSONResponse(writer, "could not upload file", http.StatusBadRequest)
+		return models.Asset{}, false
+	}
+
+	asset := models.Asset{
+		ID:          uuid.New(),
+		UserID:      userID,
+		FileType:    uploadFilePath,
+		FileName:    fileHeader.Filename,
+		StorageKey:  uploadKey,
+		IsPrivate:   isPrivate,
+		Size:        fileHeader.Size,
+		ContentType: contentType,
+		ContentHash: contentHash,
+	}
+	if !isPrivate {
+		asset.URL = storage.Store.URL(uploadKey)
+	}
+	err = models.CreateAsset(asset)
+	if err != nil {
+		// A concurrent upload of the same bytes may have won the race for this key.
+		if existingAsset, err := models.GetUserAssetByHash(userID, uploadFilePath, contentHash); err == nil {
+			return existingAsset, true
+		}
+		// Do not leave an object behind that nobody owns.
+		_ = storage.Store.Delete(request.Context(), uploadKey)
+		utils.JSONResponse(writer, "could not record file", http.StatusInternalServerError)
+		return models.Asset{}, false
+	}
+
+	if isPrivate {
+		var preview models.AssetRendition
+		if _, err = file.Seek(0, io.SeekStart); err == nil {
+			preview, err = renditions.CreateWatermarkedPreview(request.Context(), asset, file)
+		}
+		if err == nil {
+			err = models.SetAssetURL(asset.ID, preview.URL)
+		}
+		if err != nil {
+			log.Printf("Unable to watermark asset %v: %v", asset.ID, err)
+			_ = renditions.Delete(request.Context(), asset)
+			_ = storage.Store.Delete(request.Context(), uploadKey)
+			_ = models.DeleteAsset(asset.ID)
+			utils.JSONResponse(writer, "could not create watermarked preview", http.StatusBadRequest)
+			return models.Asset{}, false
+		}
+	}
+
+	// Portfolio images get smaller renditions so listings never ship the original.
+	if uploadFilePath == "PORTFOLIO" && renditions.Supported(contentType) {
+		if _, err = file.Seek(0, io.SeekStart); err == nil {
+			_, err = renditions.Create(request.Context(), asset, file)
+		}
+		if err != nil {
+			log.Printf("Unable to create renditions for asset %v: %v", asset.ID, err)
+		}
+	}
+
+	asset, _ = models.GetAsset(asset.ID)
+	return asset, true
+}
+
 // Misc godoc
 // @Tags Misc
 // @Summary UploadFile
 // @Accept multipart/form-data
 // @Produce json
 // @Security BearerAuth
-// @Param fileType query string true "Type of the file" Enums(PROFILE, PORTFOLIO)
+// @Security APIKeyAuth
+// @Param fileType query string true "Type of the file" Enums(PROFILE, PORTFOLIO, PAYWALLED)
 // @Param files formData []file true "Files to upload" multiple=true
 // @Router /api/v1/misc/upload-file [post]
-// @Success  200  {object} []string
+// @Success  200  {object} []models.Asset
 // @Failure      400  {object} schemas.ErrorPayload
 func UploadFile(writer http.ResponseWriter, request *http.Request) {
-	var fileURL []string
+	var assets []models.Asset
+
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
 	uploadFilePath := request.URL.Query().Get("fileType")
 	if uploadFilePath == "" {
 		utils.JSONResponse(writer, "fileType is required", http.StatusBadRequest)
 		return
 	}
+	if validate.Var(uploadFilePath, "oneof=PROFILE PORTFOLIO PAYWALLED") != nil {
+		utils.JSONResponse(writer, "fileType must be one of PROFILE, PORTFOLIO, PAYWALLED", http.StatusBadRequest)
+		return
+	}
 
-	err := request.ParseMultipartForm(maxUploadFile)
+	err = request.ParseMultipartForm(maxUploadFile)
 	if err != nil {
 		utils.JSONResponse(writer, "could not parse files", http.StatusBadRequest)
 		return
@@ -42,65 +184,160 @@ func UploadFile(writer http.ResponseWriter, request *http.Request) {
 		utils.JSONResponse(writer, "no files provided", http.StatusBadRequest)
 		return
 	}
-	for _, fileHeader := range files {
+	// Paywalled originals go below the private prefix and are only ever shown watermarked.
+	isPrivate := uploadFilePath == "PAYWALLED"
 
-		file, err := fileHeader.Open()
-
-		if err != nil {
-			utils.JSONResponse(writer, "could not open file", http.StatusBadRequest)
+	for _, fileHeader := range files {
+		asset, ok := storeUpload(writer, request, userID, uploadFilePath, isPrivate, fileHeader)
+		if !ok {
 			return
 		}
-		defer file.Close()
-		cloudFlareURL, err := utils.UploadPictures(fileHeader.Filename, uploadFilePath, file)
+		assets = append(assets, asset)
+	}
+	detail, _ := json.Marshal(assets)
+	utils.DSJsonResponse(writer, detail, http.StatusOK)
+}
 
-		if err != nil {
-			utils.JSONResponse(writer, "could not upload file", http.StatusBadRequest)
-			return
-		}
+// accessibleAsset loads the asset named in the URL and checks the caller owns it, admins skip the check.
+// It writes the error response itself and returns false when the handler should stop.
+func accessibleAsset(writer http.ResponseWriter, request *http.Request) (models.Asset, bool) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return models.Asset{}, false
+	}
+
+	assetID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "file not found", http.StatusNotFound)
+		return models.Asset{}, false
+	}
 
-		fileURL = append(fileURL, cloudFlareURL)
+	asset, err := models.GetAsset(assetID)
+	if err != nil {
+		utils.JSONResponse(writer, "file not found", http.StatusNotFound)
+		return models.Asset{}, false
+	}
 
+	if asset.UserID != userID && utils.ContextRole(request) != string(models.RoleAdmin) {
+		utils.JSONResponse(writer, "you do not own this file", http.StatusForbidden)
+		return models.Asset{}, false
 	}
-	detail, _ := json.Marshal(fileURL)
-	utils.DSJsonResponse(writer, detail, http.StatusOK)
+	return asset, true
+}
+
+// Misc godoc
+// @Tags Misc
+// @Summary List My Files
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param limit query int false "Page size"
+// @Param offset query int false "Page offset"
+// @Router /api/v1/misc/files [get]
+// @Success  200  {object} []models.Asset
+// @Failure      400  {object} schemas.ErrorPayload
+func GetMyFiles(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, offset := paginationParams(request)
+
+	assets, err := models.GetUserAssets(userID, limit, offset)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch files", http.StatusInternalServerError)
+		return
+	}
+
+	assetsJson, _ := json.Marshal(assets)
+	utils.DSJsonResponse(writer, assetsJson, http.StatusOK)
+}
+
+// Misc godoc
+// @Tags Misc
+// @Summary Get File
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path string true "File ID"
+// @Router /api/v1/misc/files/{id} [get]
+// @Success  200  {object} models.Asset
+// @Failure      404  {object} schemas.ErrorPayload
+func GetFile(writer http.ResponseWriter, request *http.Request) {
+	asset, ok := accessibleAsset(writer, request)
+	if !ok {
+		return
+	}
+
+	assetJson, _ := json.Marshal(asset)
+	utils.DSJsonResponse(writer, assetJson, http.StatusOK)
 }
 
 // Misc godoc
 // @Tags Misc
 // @Summary DeleteFile
-// @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param user body schemas.DeletePayload true "DeleteFile Payload"
-// @Router /api/v1/misc/delete-file [post]
+// @Security APIKeyAuth
+// @Param id path string true "File ID"
+// @Router /api/v1/misc/files/{id} [delete]
 // @Success 200 {object} map[string]interface{}
 // @Failure      400  {object} schemas.ErrorPayload
 func DeleteFile(writer http.ResponseWriter, request *http.Request) {
-	body, _ := ioutil.ReadAll(request.Body)
-	var DeletePayload schemas.DeletePayload
-
-	err := json.Unmarshal(body, &DeletePayload)
+	asset, ok := accessibleAsset(writer, request)
+	if !ok {
+		return
+	}
 
+	err := renditions.Delete(request.Context(), asset)
+	if err == nil {
+		err = storage.Store.Delete(request.Context(), asset.StorageKey)
+	}
 	if err != nil {
-
-		utils.JSONResponse(writer, "delete file body not valid", http.StatusUnprocessableEntity)
+		utils.JSONResponse(writer, "delete file failed", http.StatusBadRequest)
 		return
 	}
 
-	err = validate.Struct(DeletePayload)
+	err = models.DeleteAsset(asset.ID)
 	if err != nil {
-		validationError := err.(validator.ValidationErrors)
-		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		utils.JSONResponse(writer, "delete file failed", http.StatusInternalServerError)
 		return
 	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+	return
 
-	err = utils.DeletePicture(DeletePayload.File)
+}
 
+// ServeFile streams objects from the local and memory storage backends,
+// R2 objects are served by the CDN instead. Private objects need a signed link.
+func ServeFile(writer http.ResponseWriter, request *http.Request) {
+	fileKey := chi.URLParam(request, "*")
+
+	// Signed links made by PresignGet may reach private objects until they expire.
+	signature := request.URL.Query().Get("signature")
+	if signature != "" {
+		verifier, ok := storage.Store.(storage.SignedURLVerifier)
+		if !ok || !verifier.VerifySignedGet(fileKey, request.URL.Query().Get("expires"), signature) {
+			utils.JSONResponse(writer, "link is invalid or has expired", http.StatusForbidden)
+			return
+		}
+		writer.Header().Set("Cache-Control", "private, no-store")
+	} else if storage.IsPrivateKey(fileKey) {
+		utils.JSONResponse(writer, "Not found", http.StatusNotFound)
+		return
+	}
+
+	reader, objectInfo, err := storage.Store.Get(request.Context(), fileKey)
 	if err != nil {
-		utils.JSONResponse(writer, "delete file failed", http.StatusBadRequest)
+		utils.JSONResponse(writer, "Not found", http.StatusNotFound)
 		return
 	}
-	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
-	return
+	defer reader.Close()
 
+	writer.Header().Set("Content-Type", objectInfo.ContentType)
+	writer.Header().Set("Content-Length", strconv.FormatInt(objectInfo.Size, 10))
+	writer.WriteHeader(http.StatusOK)
+	io.Copy(writer, reader)
 }
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/glebarez/sqlite"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"image"
+	"image/color"
+	"image/png"
+	"lightRoom/db"
+	"lightRoom/models"
+	"lightRoom/renditions"
+	"lightRoom/storage"
+	"lightRoom/utils"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// useTestDatabase points the models at a fresh SQLite file holding tables.
+func useTestDatabase(t *testing.T, tables ...interface{}) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "lightroom.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = database.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	db.Db = database
+}
+
+// setupUploadTest stores uploads in memory and creates the user they are made as.
+func setupUploadTest(t *testing.T) models.User {
+	useTestDatabase(t, &models.User{}, &models.Asset{}, &models.AssetRendition{})
+	utils.Settings = utils.EnvSetting{
+		Environment:          "test",
+		PrivateStoragePrefix: "private",
+		RenditionSizes:       "thumb:16,medium:32",
+		WatermarkText:        "LightRoom",
+		WatermarkOpacity:     40,
+		WatermarkPreviewSize: "medium",
+	}
+	storage.Store = storage.NewMemoryStorage("http://files.test", "signing-secret")
+	renditions.Init()
+	InitializeValidator()
+
+	user := models.User{ID: uuid.New(), Name: "Uploader", Email: "uploader@example.com", Password: "hash", IsVerified: true, Role: models.RoleUser}
+	if err := models.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func testPNG(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for x := 0; x < 64; x++ {
+		img.Set(x, x%48, color.RGBA{R: 200, A: 255})
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+	return encoded.Bytes()
+}
+
+// asUser places userID in the request context the way LightRoomTicator does.
+func asUser(request *http.Request, userID uuid.UUID) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), "user_id", userID.String()))
+}
+
+func uploadFile(t *testing.T, userID uuid.UUID, fileType string, content []byte) models.Asset {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("files", "photo.png")
+	part.Write(content)
+	form.Close()
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/misc/upload-file?fileType="+fileType, &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	UploadFile(recorder, asUser(request, userID))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("uploading as %s returned %d: %s", fileType, recorder.Code, recorder.Body)
+	}
+
+	var assets []models.Asset
+	if err := json.Unmarshal(recorder.Body.Bytes(), &assets); err != nil || len(assets) != 1 {
+		t.Fatalf("upload response %s: %v", recorder.Body, err)
+	}
+	stored, err := models.GetAsset(assets[0].ID)
+	if err != nil {
+		t.Fatalf("uploaded asset was not recorded: %v", err)
+	}
+	return stored
+}
+
+func TestUploadDeduplicatesSameFileType(t *testing.T) {
+	user := setupUploadTest(t)
+	content := testPNG(t)
+
+	first := uploadFile(t, user.ID, "PORTFOLIO", content)
+	second := uploadFile(t, user.ID, "PORTFOLIO", content)
+	if first.ID != second.ID {
+		t.Errorf("the same bytes uploaded twice as PORTFOLIO made two assets, %v and %v", first.ID, second.ID)
+	}
+	if first.ContentType != "image/png" {
+		t.Errorf("content type = %q, want the sniffed image/png", first.ContentType)
+	}
+}
+
+// Bytes first uploaded as a profile picture are a new asset when uploaded to a portfolio,
+// stored under the portfolio key and given renditions.
+func TestUploadDoesNotDeduplicateAcrossFileTypes(t *testing.T) {
+	user := setupUploadTest(t)
+	content := testPNG(t)
+
+	profile := uploadFile(t, user.ID, "PROFILE", content)
+	portfolio := uploadFile(t, user.ID, "PORTFOLIO", content)
+
+	if profile.ID == portfolio.ID {
+		t.Fatal("the PORTFOLIO upload returned the PROFILE asset")
+	}
+	if portfolio.FileType != "PORTFOLIO" || portfolio.StorageKey == profile.StorageKey {
+		t.Errorf("PORTFOLIO asset is %s under %q, PROFILE is under %q", portfolio.FileType, portfolio.StorageKey, profile.StorageKey)
+	}
+	if len(profile.Renditions) != 0 {
+		t.Errorf("PROFILE asset has %d renditions, want none", len(profile.Renditions))
+	}
+	if len(portfolio.Renditions) != 2 {
+		t.Errorf("PORTFOLIO asset has %d renditions, want one per configured size", len(portfolio.Renditions))
+	}
+	if _, err := storage.Store.Stat(context.Background(), portfolio.StorageKey); err != nil {
+		t.Errorf("PORTFOLIO original was not stored: %v", err)
+	}
+}
+
+func TestDeleteFileChecksOwnership(t *testing.T) {
+	owner := setupUploadTest(t)
+	other := models.User{ID: uuid.New(), Name: "Other", Email: "other@example.com", Password: "hash", IsVerified: true, Role: models.RoleUser}
+	if err := models.CreateUser(other); err != nil {
+		t.Fatal(err)
+	}
+	asset := uploadFile(t, owner.ID, "PORTFOLIO", testPNG(t))
+
+	router := chi.NewRouter()
+	router.Delete("/api/v1/misc/files/{id}", DeleteFile)
+	deleteAs := func(userID uuid.UUID) int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodDelete, "/api/v1/misc/files/"+asset.ID.String(), nil)
+		router.ServeHTTP(recorder, asUser(request, userID))
+		return recorder.Code
+	}
+
+	if code := deleteAs(other.ID); code != http.StatusForbidden {
+		t.Fatalf("deleting another user's file returned %d, want 403", code)
+	}
+	if _, err := models.GetAsset(asset.ID); err != nil {
+		t.Fatal("a refused delete removed the asset")
+	}
+
+	if code := deleteAs(owner.ID); code != http.StatusOK {
+		t.Fatalf("deleting an owned file returned %d, want 200", code)
+	}
+	if _, err := models.GetAsset(asset.ID); err == nil {
+		t.Error("the asset is still recorded after it was deleted")
+	}
+	objects, _ := storage.Store.List(context.Background(), "")
+	if len(objects) != 0 {
+		t.Errorf("%d stored objects are left behind after the delete", len(objects))
+	}
+}
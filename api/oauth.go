@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"lightRoom/cache"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/sso"
+	"lightRoom/utils"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// oauthFlow is kept in Redis under the state parameter between start and callback.
+type oauthFlow struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+// OAuth godoc
+// @Tags OAuth
+// @Summary List Social Login Providers
+// @Produce json
+// @Router /api/v1/auth/oauth/providers [get]
+// @Success  200  {object} []string
+func GetOAuthProviders(writer http.ResponseWriter, request *http.Request) {
+	providersJson, _ := json.Marshal(sso.Names())
+	utils.DSJsonResponse(writer, providersJson, http.StatusOK)
+}
+
+// OAuth godoc
+// @Tags OAuth
+// @Summary Start Social Login
+// @Description Returns the provider's sign-in URL to send the browser to
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Router /api/v1/auth/oauth/{provider}/start [get]
+// @Success  200  {object} schemas.OAuthStartPayload
+// @Failure      404  {object} schemas.ErrorPayload
+func StartOAuthLogin(writer http.ResponseWriter, request *http.Request) {
+	provider, err := sso.Get(chi.URLParam(request, "provider"))
+	if err != nil {
+		utils.JSONResponse(writer, "provider not found", http.StatusNotFound)
+		return
+	}
+
+	state := utils.SecureToken(32)
+	flow := oauthFlow{Provider: provider.Name(), Verifier: oauth2.GenerateVerifier(), Nonce: utils.SecureToken(32)}
+	authorizationURL, err := provider.AuthCodeURL(request.Context(), state, flow.Nonce, flow.Verifier)
+	if err != nil {
+		log.Printf("Unable to reach %v: %v", provider.Name(), err)
+		utils.JSONResponse(writer, "provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	flowJson, _ := json.Marshal(flow)
+	err = cache.SetOAuthState(state, flowJson)
+	if err != nil {
+		utils.JSONResponse(writer, "could not start login", http.StatusInternalServerError)
+		return
+	}
+
+	startJson, _ := json.Marshal(schemas.OAuthStartPayload{AuthorizationURL: authorizationURL})
+	utils.DSJsonResponse(writer, startJson, http.StatusOK)
+}
+
+// linkIdentity finds the user behind an external identity. Unknown identities are linked
+// to the account with the same verified email, or get a new account when there is none.
+func linkIdentity(provider string, identity sso.Identity) (models.User, error) {
+	linked, err := models.GetExternalIdentity(provider, identity.Subject)
+	if err == nil {
+		return models.GetUser(linked.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.User{}, err
+	}
+
+	// Linking on an address the provider has not verified would let anyone claim any account.
+	if identity.Email == "" || !identity.EmailVerified {
+		return models.User{}, sso.ErrEmailNotVerified
+	}
+
+	user, err := models.FetchViaMail(identity.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		name := identity.Name
+		if name == "" {
+			name, _, _ = strings.Cut(identity.Email, "@")
+		}
+		user = models.User{
+			ID:    uuid.New(),
+			Name:  name,
+			Email: identity.Email,
+			// No password can match this, the account signs in through the provider
+			// until a password is set with a reset.
+			Password:   utils.UnusablePassword(),
+			IsVerified: true,
+			Role:       models.RoleUser,
+		}
+		err = models.CreateUser(user)
+	} else if err == nil && !user.IsVerified {
+		// Someone may have signed up with this address without owning it, the provider
+		// has now proven who does, so the password chosen at sign up is discarded.
+		user.IsVerified = true
+		err = models.ClaimUnverifiedUser(user.ID, utils.UnusablePassword())
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	err = models.CreateExternalIdentity(models.ExternalIdentity{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	return user, err
+}
+
+// OAuth godoc
+// @Tags OAuth
+// @Summary Social Login Callback
+// @Description Where the provider sends the browser back to, answers like login
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the start step"
+// @Router /api/v1/auth/oauth/{provider}/callback [get]
+// @Success  200  {object}  schemas.AccessPayload
+// @Success  202  {object}  schemas.MfaChallengePayload
+// @Failure      400  {object} schemas.ErrorPayload
+func OAuthCallback(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		utils.JSONResponse(writer, "sign in was cancelled or refused: "+providerError, http.StatusBadRequest)
+		return
+	}
+
+	var flow oauthFlow
+	flowJson, err := cache.TakeOAuthState(query.Get("state"))
+	if err == nil {
+		err = json.Unmarshal(flowJson, &flow)
+	}
+	if err != nil || flow.Provider != chi.URLParam(request, "provider") {
+		utils.JSONResponse(writer, "login has expired or is invalid, start again", http.StatusBadRequest)
+		return
+	}
+	provider, err := sso.Get(flow.Provider)
+	if err != nil {
+		utils.JSONResponse(writer, "provider not found", http.StatusNotFound)
+		return
+	}
+
+	identity, err := provider.Identity(request.Context(), query.Get("code"), flow.Nonce, flow.Verifier)
+	if err != nil {
+		log.Printf("Unable to complete %v login: %v", flow.Provider, err)
+		utils.JSONResponse(writer, "could not verify sign in with provider", http.StatusBadRequest)
+		return
+	}
+
+	user, err := linkIdentity(flow.Provider, identity)
+	if errors.Is(err, sso.ErrEmailNotVerified) {
+		utils.JSONResponse(writer, "the provider account has no verified email", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Unable to link %v identity: %v", flow.Provider, err)
+		utils.JSONResponse(writer, "could not sign in", http.StatusInternalServerError)
+		return
+	}
+	// The token pair is the body of a browser GET, keep it out of every cache.
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.Header().Set("Pragma", "no-cache")
+	completeLogin(writer, request, user)
+}
@@ -0,0 +1,342 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/redis/go-redis/v9"
+	"lightRoom/cache"
+	"lightRoom/db"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/sso"
+	"lightRoom/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testOIDCClientID = "lightroom-test"
+
+// fakeAuthorization is what the fake provider remembers about a code between authorize and token.
+type fakeAuthorization struct {
+	challenge string
+	nonce     string
+	identity  sso.Identity
+}
+
+// fakeOIDCProvider is an OpenID Connect issuer served by httptest. It checks the PKCE verifier
+// on the token endpoint and signs ID tokens that carry the nonce given at authorize.
+type fakeOIDCProvider struct {
+	server     *httptest.Server
+	signingKey jwk.Key
+	publicKeys jwk.Set
+
+	mutex sync.Mutex
+	codes map[string]fakeAuthorization
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey, err := jwk.New(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = signingKey.Set(jwk.KeyIDKey, "test-key")
+	publicKey, err := jwk.PublicKeyOf(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = publicKey.Set(jwk.AlgorithmKey, jwa.RS256)
+	publicKeys := jwk.NewSet()
+	publicKeys.Add(publicKey)
+
+	provider := &fakeOIDCProvider{signingKey: signingKey, publicKeys: publicKeys, codes: map[string]fakeAuthorization{}}
+	router := chi.NewRouter()
+	router.Get("/.well-known/openid-configuration", provider.discovery)
+	router.Get("/jwks", provider.jwks)
+	router.Post("/token", provider.token)
+	provider.server = httptest.NewServer(router)
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+func (provider *fakeOIDCProvider) discovery(writer http.ResponseWriter, request *http.Request) {
+	issuer := provider.server.URL
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (provider *fakeOIDCProvider) jwks(writer http.ResponseWriter, request *http.Request) {
+	json.NewEncoder(writer).Encode(provider.publicKeys)
+}
+
+func (provider *fakeOIDCProvider) token(writer http.ResponseWriter, request *http.Request) {
+	_ = request.ParseForm()
+	provider.mutex.Lock()
+	authorization, ok := provider.codes[request.PostForm.Get("code")]
+	delete(provider.codes, request.PostForm.Get("code"))
+	provider.mutex.Unlock()
+
+	verifierHash := sha256.Sum256([]byte(request.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifierHash[:]) != authorization.challenge {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(`{"error": "invalid_grant"}`))
+		return
+	}
+
+	idToken := jwt.New()
+	_ = idToken.Set(jwt.IssuerKey, provider.server.URL)
+	_ = idToken.Set(jwt.SubjectKey, authorization.identity.Subject)
+	_ = idToken.Set(jwt.AudienceKey, []string{testOIDCClientID})
+	_ = idToken.Set(jwt.IssuedAtKey, time.Now())
+	_ = idToken.Set(jwt.ExpirationKey, time.Now().Add(time.Hour))
+	_ = idToken.Set("nonce", authorization.nonce)
+	_ = idToken.Set("email", authorization.identity.Email)
+	_ = idToken.Set("email_verified", authorization.identity.EmailVerified)
+	_ = idToken.Set("name", authorization.identity.Name)
+	signed, err := jwt.Sign(idToken, jwa.RS256, provider.signingKey)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     string(signed),
+	})
+}
+
+// authorize stands in for the user signing in at the provider, it returns the code and state
+// the browser would be redirected back with.
+func (provider *fakeOIDCProvider) authorize(t *testing.T, authorizationURL string, identity sso.Identity) (string, string) {
+	t.Helper()
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("authorization URL %q: %v", authorizationURL, err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL has no S256 PKCE challenge: %v", authorizationURL)
+	}
+	if query.Get("state") == "" || query.Get("nonce") == "" {
+		t.Fatalf("authorization URL has no state or nonce: %v", authorizationURL)
+	}
+	if query.Get("client_id") != testOIDCClientID {
+		t.Fatalf("authorization URL client_id = %q", query.Get("client_id"))
+	}
+
+	code := uuid.NewString()
+	provider.mutex.Lock()
+	provider.codes[code] = fakeAuthorization{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), identity: identity}
+	provider.mutex.Unlock()
+	return code, query.Get("state")
+}
+
+// setupOAuthTest points the cache at miniredis, the models at a fresh SQLite file and
+// configures the fake provider under the name "test".
+func setupOAuthTest(t *testing.T) (*fakeOIDCProvider, http.Handler) {
+	redisServer := miniredis.RunT(t)
+	cache.LRedis = redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+
+	useTestDatabase(t, &models.User{}, &models.ExternalIdentity{})
+
+	provider := newFakeOIDCProvider(t)
+	utils.Settings = utils.EnvSetting{
+		AccessTokenExpiry:    time.Hour,
+		RefreshTokenExpiry:   2 * time.Hour,
+		MfaChallengeExpiry:   5 * time.Minute,
+		OAuthCallbackBaseUrl: "http://lightroom.test/api/v1/auth/oauth",
+		OidcProviderName:     "test",
+		OidcIssuerUrl:        provider.server.URL,
+		OidcClientID:         testOIDCClientID,
+		OidcClientSecret:     "test-secret",
+	}
+	utils.TokenAuth = jwtauth.New("HS256", []byte("test-jwt-secret"), nil)
+	InitializeValidator()
+	sso.Providers = map[string]sso.Provider{}
+	sso.Init()
+
+	router := chi.NewRouter()
+	router.Get("/api/v1/auth/oauth/{provider}/start", StartOAuthLogin)
+	router.Get("/api/v1/auth/oauth/{provider}/callback", OAuthCallback)
+	return provider, router
+}
+
+func serveTest(handler http.Handler, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+func startOAuthLogin(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	response := serveTest(handler, "/api/v1/auth/oauth/test/start")
+	if response.Code != http.StatusOK {
+		t.Fatalf("start returned %d: %s", response.Code, response.Body)
+	}
+	var startPayload schemas.OAuthStartPayload
+	if err := json.Unmarshal(response.Body.Bytes(), &startPayload); err != nil {
+		t.Fatal(err)
+	}
+	return startPayload.AuthorizationURL
+}
+
+func oauthCallback(handler http.Handler, code, state string) *httptest.ResponseRecorder {
+	query := url.Values{"code": {code}, "state": {state}}
+	return serveTest(handler, "/api/v1/auth/oauth/test/callback?"+query.Encode())
+}
+
+func TestOAuthLoginCreatesAccount(t *testing.T) {
+	provider, handler := setupOAuthTest(t)
+
+	code, state := provider.authorize(t, startOAuthLogin(t, handler), sso.Identity{
+		Subject: "subject-1", Email: "New.User@Example.com", EmailVerified: true, Name: "New User",
+	})
+	response := oauthCallback(handler, code, state)
+	if response.Code != http.StatusOK {
+		t.Fatalf("callback returned %d: %s", response.Code, response.Body)
+	}
+	var tokens map[string]string
+	_ = json.Unmarshal(response.Body.Bytes(), &tokens)
+	if tokens["access_token"] == "" || tokens["refresh_token"] == "" {
+		t.Errorf("callback did not issue tokens: %s", response.Body)
+	}
+	if response.Header().Get("Cache-Control") != "no-store" || response.Header().Get("Pragma") != "no-cache" {
+		t.Errorf("callback tokens may be cached: Cache-Control %q, Pragma %q", response.Header().Get("Cache-Control"), response.Header().Get("Pragma"))
+	}
+
+	user, err := models.FetchViaMail("new.user@example.com")
+	if err != nil {
+		t.Fatalf("no account was created: %v", err)
+	}
+	if user.Email != "new.user@example.com" || !user.IsVerified || !utils.IsUnusablePassword(user.Password) {
+		t.Errorf("created account = %+v", user)
+	}
+	identity, err := models.GetExternalIdentity("test", "subject-1")
+	if err != nil || identity.UserID != user.ID {
+		t.Errorf("identity was not linked to the new account: %+v, %v", identity, err)
+	}
+
+	// The state is single use, replaying the callback must not sign in again.
+	if replay := oauthCallback(handler, code, state); replay.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback returned %d, want 400", replay.Code)
+	}
+}
+
+func TestOAuthLoginRejectsAnotherFlowsVerifier(t *testing.T) {
+	provider, handler := setupOAuthTest(t)
+
+	code, _ := provider.authorize(t, startOAuthLogin(t, handler), sso.Identity{
+		Subject: "subject-1", Email: "user@example.com", EmailVerified: true,
+	})
+	_, otherState := provider.authorize(t, startOAuthLogin(t, handler), sso.Identity{})
+
+	// The code was issued against the first flow's challenge, the second flow's verifier cannot redeem it.
+	if response := oauthCallback(handler, code, otherState); response.Code != http.StatusBadRequest {
+		t.Fatalf("callback with a mismatched verifier returned %d, want 400", response.Code)
+	}
+	if _, err := models.FetchViaMail("user@example.com"); err == nil {
+		t.Error("an account was created from a rejected callback")
+	}
+}
+
+func TestOAuthLoginRejectsUnknownState(t *testing.T) {
+	provider, handler := setupOAuthTest(t)
+
+	code, _ := provider.authorize(t, startOAuthLogin(t, handler), sso.Identity{
+		Subject: "subject-1", Email: "user@example.com", EmailVerified: true,
+	})
+	if response := oauthCallback(handler, code, "forged-state"); response.Code != http.StatusBadRequest {
+		t.Fatalf("callback with a forged state returned %d, want 400", response.Code)
+	}
+}
+
+func TestOAuthLoginLinksExistingAccount(t *testing.T) {
+	provider, handler := setupOAuthTest(t)
+	existing := models.User{ID: uuid.New(), Name: "Existing", Email: "existing@example.com", Password: "existing-hash", IsVerified: true, Role: models.RoleUser}
+	if err := models.CreateUser(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		code, state := provider.authorize(t, startOAuthLogin(t, handler), sso.Identity{
+			Subject: "subject-2", Email: "Existing@Example.COM", EmailVerified: true,
+		})
+		if response := oauthCallback(handler, code, state); response.Code != http.StatusOK {
+			t.Fatalf("callback %d returned %d: %s", attempt, response.Code, response.Body)
+		}
+	}
+
+	identity, err := models.GetExternalIdentity("test", "subject-2")
+	if err != nil || identity.UserID != existing.ID {
+		t.Fatalf("identity was not linked to the existing account: %+v, %v", identity, err)
+	}
+	user, _ := models.GetUser(existing.ID)
+	if user.Password != "existing-hash" {
+		t.Error("linking replaced the password of a verified account")
+	}
+	var count int64
+	db.Db.Model(&models.User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d accounts exist, linking should not have created one", count)
+	}
+}
+
+func TestOAuthLoginClaimsUnverifiedAccount(t *testing.T) {
+	provider, handler := setupOAuthTest(t)
+	squatted := models.User{ID: uuid.New(), Name: "Squatter", Email: "owner@example.com", Password: "squatter-hash", Role: models.RoleUser}
+	if err := models.CreateUser(squatted); err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := provider.authorize(t, startOAuthLogin(t, handler), sso.Identity{
+		Subject: "subject-3", Email: "owner@example.com", EmailVerified: true,
+	})
+	if response := oauthCallback(handler, code, state); response.Code != http.StatusOK {
+		t.Fatalf("callback returned %d: %s", response.Code, response.Body)
+	}
+
+	user, _ := models.GetUser(squatted.ID)
+	if !user.IsVerified || !utils.IsUnusablePassword(user.Password) {
+		t.Errorf("unverified account was not claimed: verified %v, password %q", user.IsVerified, user.Password)
+	}
+}
+
+func TestOAuthLoginRequiresVerifiedEmail(t *testing.T) {
+	provider, handler := setupOAuthTest(t)
+
+	code, state := provider.authorize(t, startOAuthLogin(t, handler), sso.Identity{
+		Subject: "subject-4", Email: "unverified@example.com", EmailVerified: false,
+	})
+	if response := oauthCallback(handler, code, state); response.Code != http.StatusBadRequest {
+		t.Fatalf("callback with an unverified email returned %d, want 400", response.Code)
+	}
+	if _, err := models.GetExternalIdentity("test", "subject-4"); err == nil {
+		t.Error("an identity with an unverified email was linked")
+	}
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"lightRoom/models"
+	"lightRoom/payments"
+	"lightRoom/schemas"
+	"lightRoom/utils"
+	"log"
+	"net/http"
+)
+
+// Orders godoc
+// @Tags Orders
+// @Summary Checkout
+// @Description Opens a payment for the given portfolios and returns where to pay for it
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param checkout body schemas.CheckoutPayload true "Portfolios to buy"
+// @Router /api/v1/orders/checkout [post]
+// @Success  201  {object} schemas.CheckoutResponsePayload
+// @Failure      400  {object} schemas.ErrorPayload
+func Checkout(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var checkoutPayload schemas.CheckoutPayload
+
+	err = json.Unmarshal(body, &checkoutPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "checkout body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(checkoutPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	portfolioIDs := parseUUIDs(checkoutPayload.PortfolioIDs)
+	portfolios, err := models.GetPortfoliosByID(portfolioIDs)
+	if err != nil || len(portfolios) != len(portfolioIDs) {
+		utils.JSONResponse(writer, "one or more portfolios do not exist", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUser(userID)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	order := models.Order{
+		ID:       uuid.New(),
+		UserID:   userID,
+		Status:   models.OrderPending,
+		Currency: utils.Settings.Currency,
+		Provider: payments.Provider.Name(),
+	}
+	for _, portfolio := range portfolios {
+		switch {
+		case portfolio.UserID == userID:
+			utils.JSONResponse(writer, "you cannot buy your own portfolio", http.StatusBadRequest)
+			return
+		case portfolio.Price == 0:
+			utils.JSONResponse(writer, "free portfolios do not need to be bought", http.StatusBadRequest)
+			return
+		case models.HasEntitlement(userID, portfolio.ID):
+			utils.JSONResponse(writer, "you already own one or more of these portfolios", http.StatusConflict)
+			return
+		}
+		// Items keep the title and price at the time of sale, later edits do not change the order.
+		order.Items = append(order.Items, models.OrderItem{
+			ID:          uuid.New(),
+			OrderID:     order.ID,
+			PortfolioID: portfolio.ID,
+			Title:       portfolio.Title,
+			Price:       portfolio.Price,
+		})
+		order.Total += portfolio.Price
+	}
+
+	err = models.CreateOrder(order)
+	if err != nil {
+		utils.JSONResponse(writer, "could not create order", http.StatusInternalServerError)
+		return
+	}
+
+	session, err := payments.Provider.CreateCheckout(request.Context(), order, user.Email)
+	if err == nil {
+		err = models.SetOrderProviderReference(order.ID, payments.Provider.Name(), session.Reference)
+	}
+	if err != nil {
+		log.Printf("Unable to open checkout for order %v: %v", order.ID, err)
+		_ = models.MarkOrderFailed(order.ID)
+		utils.JSONResponse(writer, "could not start checkout", http.StatusBadGateway)
+		return
+	}
+
+	order, _ = models.GetOrder(order.ID)
+	checkoutJson, _ := json.Marshal(schemas.CheckoutResponsePayload{Order: order, CheckoutURL: session.URL})
+	utils.DSJsonResponse(writer, checkoutJson, http.StatusCreated)
+}
+
+// Orders godoc
+// @Tags Orders
+// @Summary List My Orders
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size"
+// @Param offset query int false "Page offset"
+// @Router /api/v1/orders [get]
+// @Success  200  {object} []models.Order
+// @Failure      400  {object} schemas.ErrorPayload
+func GetMyOrders(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, offset := paginationParams(request)
+
+	orders, err := models.GetUserOrders(userID, limit, offset)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch orders", http.StatusInternalServerError)
+		return
+	}
+
+	ordersJson, _ := json.Marshal(orders)
+	utils.DSJsonResponse(writer, ordersJson, http.StatusOK)
+}
+
+// Orders godoc
+// @Tags Orders
+// @Summary Get Order
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Router /api/v1/orders/{id} [get]
+// @Success  200  {object} models.Order
+// @Failure      404  {object} schemas.ErrorPayload
+func GetOrder(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orderID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "order not found", http.StatusNotFound)
+		return
+	}
+
+	order, err := models.GetOrder(orderID)
+	// Other users' orders are reported missing rather than forbidden.
+	if err != nil || (order.UserID != userID && utils.ContextRole(request) != string(models.RoleAdmin)) {
+		utils.JSONResponse(writer, "order not found", http.StatusNotFound)
+		return
+	}
+
+	orderJson, _ := json.Marshal(order)
+	utils.DSJsonResponse(writer, orderJson, http.StatusOK)
+}
+
+// Orders godoc
+// @Tags Orders
+// @Summary List My Entitlements
+// @Description Portfolios the caller has bought and may download
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size"
+// @Param offset query int false "Page offset"
+// @Router /api/v1/entitlements [get]
+// @Success  200  {object} []models.Entitlement
+// @Failure      400  {object} schemas.ErrorPayload
+func GetMyEntitlements(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, offset := paginationParams(request)
+
+	entitlements, err := models.GetUserEntitlements(userID, limit, offset)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch entitlements", http.StatusInternalServerError)
+		return
+	}
+
+	entitlementsJson, _ := json.Marshal(entitlements)
+	utils.DSJsonResponse(writer, entitlementsJson, http.StatusOK)
+}
+
+// processPaymentEvent applies the event and writes the response the provider expects.
+func processPaymentEvent(writer http.ResponseWriter, event payments.Event) {
+	err := payments.Process(event)
+	if errors.Is(err, models.ErrOrderNotFound) {
+		// Not ours, acknowledge it so the provider stops retrying.
+		log.Printf("Payment event %v references unknown checkout %v", event.ID, event.Reference)
+		utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+		return
+	}
+	if err != nil {
+		// A failed response makes the provider redeliver the event later.
+		log.Printf("Unable to process payment event %v: %v", event.ID, err)
+		utils.JSONResponse(writer, "could not process event", http.StatusInternalServerError)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+}
+
+// Payments godoc
+// @Tags Payments
+// @Summary Payment Webhook
+// @Description Receives signed events from the payment provider
+// @Accept json
+// @Produce json
+// @Router /api/v1/payments/webhook [post]
+// @Success 200 {object} map[string]interface{}
+// @Failure      400  {object} schemas.ErrorPayload
+func PaymentWebhook(writer http.ResponseWriter, request *http.Request) {
+	event, err := payments.Provider.ParseWebhook(request)
+	if err != nil {
+		utils.JSONResponse(writer, "invalid webhook", http.StatusBadRequest)
+		return
+	}
+	processPaymentEvent(writer, event)
+}
+
+// Payments godoc
+// @Tags Payments
+// @Summary Fake Checkout
+// @Description Settles a checkout opened with the fake payment provider, only mounted when it is selected
+// @Produce json
+// @Param reference path string true "Checkout reference"
+// @Param outcome query string false "Payment outcome" Enums(succeeded, failed)
+// @Router /api/v1/payments/fake/checkout/{reference} [post]
+// @Success 200 {object} map[string]interface{}
+// @Failure      400  {object} schemas.ErrorPayload
+func FakeCheckout(writer http.ResponseWriter, request *http.Request) {
+	fake, ok := payments.Provider.(*payments.FakeProvider)
+	if !ok {
+		utils.JSONResponse(writer, "Not found", http.StatusNotFound)
+		return
+	}
+
+	succeeded := request.URL.Query().Get("outcome") != "failed"
+	event := fake.Complete(chi.URLParam(request, "reference"), succeeded)
+	processPaymentEvent(writer, event)
+}
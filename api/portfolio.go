@@ -0,0 +1,478 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"io/ioutil"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/storage"
+	"lightRoom/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// paginationParams reads the limit and offset query parameters, falling back to sane defaults.
+func paginationParams(request *http.Request) (int, int) {
+	limit, err := strconv.Atoi(request.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	offset, err := strconv.Atoi(request.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// parseUUIDs parses and de-duplicates values, skipping anything that is not a UUID.
+func parseUUIDs(values []string) []uuid.UUID {
+	var parsedUUIDs []uuid.UUID
+	seen := map[uuid.UUID]bool{}
+	for _, value := range values {
+		parsedUUID, err := uuid.Parse(strings.TrimSpace(value))
+		if err == nil && !seen[parsedUUID] {
+			seen[parsedUUID] = true
+			parsedUUIDs = append(parsedUUIDs, parsedUUID)
+		}
+	}
+	return parsedUUIDs
+}
+
+// parseTagIDs parses and de-duplicates tag IDs, failing on anything that is not a UUID.
+func parseTagIDs(values []string) ([]uuid.UUID, error) {
+	for _, value := range values {
+		if _, err := uuid.Parse(strings.TrimSpace(value)); err != nil {
+			return nil, errors.New("tags must be tag IDs")
+		}
+	}
+	return parseUUIDs(values), nil
+}
+
+// tagFilterParams reads the comma separated tags query parameter and whether
+// match=all was asked for instead of the default match=any.
+func tagFilterParams(request *http.Request) (bool, []uuid.UUID, error) {
+	rawTags := request.URL.Query().Get("tags")
+	if rawTags == "" {
+		return false, nil, nil
+	}
+	matchAll := strings.EqualFold(request.URL.Query().Get("match"), "all")
+	tagIDs, err := parseTagIDs(strings.Split(rawTags, ","))
+	return matchAll, tagIDs, err
+}
+
+// resolveTags loads the tags named in tagIDs and fails if any of them is malformed or does not exist.
+func resolveTags(tagIDs []string) ([]models.Tag, error) {
+	parsedUUIDs, err := parseTagIDs(tagIDs)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := models.GetTagsByID(parsedUUIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) != len(parsedUUIDs) {
+		return nil, errors.New("one or more tags do not exist")
+	}
+	return tags, nil
+}
+
+// checkPaywalledImages makes sure every paywalled image is the watermarked preview of a
+// private asset the user uploaded, so a clean original can never be listed as paywalled.
+func checkPaywalledImages(userID uuid.UUID, paywalledImages []string) error {
+	assets, err := models.GetUserPrivateAssetsByURL(userID, paywalledImages)
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, asset := range assets {
+		known[asset.URL] = true
+	}
+	for _, imageURL := range paywalledImages {
+		if !known[imageURL] {
+			return errors.New("paywalled images must be uploaded with fileType PAYWALLED")
+		}
+	}
+	return nil
+}
+
+// Portfolio godoc
+// @Tags Portfolio
+// @Summary Create Portfolio
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param portfolio body schemas.PortfolioPayload true "Create Portfolio Payload"
+// @Router /api/v1/portfolios [post]
+// @Success  201  {object}  models.Portfolio
+// @Failure      400  {object} schemas.ErrorPayload
+func CreatePortfolio(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var portfolioPayload schemas.PortfolioPayload
+
+	err = json.Unmarshal(body, &portfolioPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(portfolioPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tags, err := resolveTags(portfolioPayload.Tags)
+	if err != nil {
+		utils.JSONResponse(writer, "one or more tags do not exist", http.StatusBadRequest)
+		return
+	}
+
+	err = checkPaywalledImages(userID, portfolioPayload.PaywalledImages)
+	if err != nil {
+		utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	portfolio := models.Portfolio{
+		ID:              uuid.New(),
+		Title:           portfolioPayload.Title,
+		Description:     portfolioPayload.Description,
+		Price:           portfolioPayload.Price,
+		Tags:            tags,
+		Images:          portfolioPayload.Images,
+		PaywalledImages: portfolioPayload.PaywalledImages,
+		UserID:          userID,
+	}
+
+	err = models.CreatePortfolio(portfolio)
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio creation error", http.StatusInternalServerError)
+		return
+	}
+
+	portfolio, _ = models.GetPortfolio(portfolio.ID)
+	portfolioJson, _ := json.Marshal(portfolio)
+	utils.DSJsonResponse(writer, portfolioJson, http.StatusCreated)
+}
+
+// Portfolio godoc
+// @Tags Portfolio
+// @Summary Get Portfolio
+// @Produce json
+// @Param id path string true "Portfolio ID"
+// @Router /api/v1/portfolios/{id} [get]
+// @Success  200  {object}  models.Portfolio
+// @Failure      404  {object} schemas.ErrorPayload
+func GetPortfolio(writer http.ResponseWriter, request *http.Request) {
+	portfolioID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio not found", http.StatusNotFound)
+		return
+	}
+
+	portfolio, err := models.GetPortfolio(portfolioID)
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio not found", http.StatusNotFound)
+		return
+	}
+
+	portfolios := []models.Portfolio{portfolio}
+	_ = models.AttachImagePreviews(portfolios)
+	portfolioJson, _ := json.Marshal(portfolios[0])
+	utils.DSJsonResponse(writer, portfolioJson, http.StatusOK)
+}
+
+// Portfolio godoc
+// @Tags Portfolio
+// @Summary List Public Portfolios
+// @Produce json
+// @Param limit query int false "Page size"
+// @Param offset query int false "Page offset"
+// @Param tags query string false "Comma separated tag IDs"
+// @Param match query string false "Tag match mode" Enums(any, all)
+// @Router /api/v1/portfolios [get]
+// @Success  200  {object}  []models.Portfolio
+// @Failure      400  {object} schemas.ErrorPayload
+func GetPortfolios(writer http.ResponseWriter, request *http.Request) {
+	limit, offset := paginationParams(request)
+	matchAll, tagIDs, err := tagFilterParams(request)
+	if err != nil {
+		utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	portfolios, err := models.GetPortfolios(limit, offset, matchAll, tagIDs...)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch portfolios", http.StatusInternalServerError)
+		return
+	}
+
+	_ = models.AttachImagePreviews(portfolios)
+	portfoliosJson, _ := json.Marshal(portfolios)
+	utils.DSJsonResponse(writer, portfoliosJson, http.StatusOK)
+}
+
+// Portfolio godoc
+// @Tags Portfolio
+// @Summary List My Portfolios
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param limit query int false "Page size"
+// @Param offset query int false "Page offset"
+// @Param tags query string false "Comma separated tag IDs"
+// @Param match query string false "Tag match mode" Enums(any, all)
+// @Router /api/v1/portfolios/me [get]
+// @Success  200  {object}  []models.Portfolio
+// @Failure      400  {object} schemas.ErrorPayload
+func GetMyPortfolios(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, offset := paginationParams(request)
+	matchAll, tagIDs, err := tagFilterParams(request)
+	if err != nil {
+		utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	portfolios, err := models.GetUserPortfolios(userID, limit, offset, matchAll, tagIDs...)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch portfolios", http.StatusInternalServerError)
+		return
+	}
+
+	_ = models.AttachImagePreviews(portfolios)
+	portfoliosJson, _ := json.Marshal(portfolios)
+	utils.DSJsonResponse(writer, portfoliosJson, http.StatusOK)
+}
+
+// ownedPortfolio loads the portfolio named in the URL and checks it belongs to the caller.
+// It writes the error response itself and returns false when the handler should stop.
+func ownedPortfolio(writer http.ResponseWriter, request *http.Request) (models.Portfolio, bool) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return models.Portfolio{}, false
+	}
+
+	portfolioID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio not found", http.StatusNotFound)
+		return models.Portfolio{}, false
+	}
+
+	portfolio, err := models.GetPortfolio(portfolioID)
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio not found", http.StatusNotFound)
+		return models.Portfolio{}, false
+	}
+
+	if portfolio.UserID != userID {
+		utils.JSONResponse(writer, "you do not own this portfolio", http.StatusForbidden)
+		return models.Portfolio{}, false
+	}
+	return portfolio, true
+}
+
+// Portfolio godoc
+// @Tags Portfolio
+// @Summary Update Portfolio
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path string true "Portfolio ID"
+// @Param portfolio body schemas.PortfolioUpdatePayload true "Update Portfolio Payload"
+// @Router /api/v1/portfolios/{id} [patch]
+// @Success  200  {object}  models.Portfolio
+// @Failure      400  {object} schemas.ErrorPayload
+func UpdatePortfolio(writer http.ResponseWriter, request *http.Request) {
+	portfolio, ok := ownedPortfolio(writer, request)
+	if !ok {
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var updatePayload schemas.PortfolioUpdatePayload
+
+	err := json.Unmarshal(body, &updatePayload)
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(updatePayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if updatePayload.Title != nil {
+		portfolio.Title = *updatePayload.Title
+	}
+	if updatePayload.Description != nil {
+		portfolio.Description = *updatePayload.Description
+	}
+	if updatePayload.Price != nil {
+		portfolio.Price = *updatePayload.Price
+	}
+	if updatePayload.Images != nil {
+		portfolio.Images = *updatePayload.Images
+	}
+	if updatePayload.PaywalledImages != nil {
+		err = checkPaywalledImages(portfolio.UserID, *updatePayload.PaywalledImages)
+		if err != nil {
+			utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		portfolio.PaywalledImages = *updatePayload.PaywalledImages
+	}
+
+	// Tags are checked before anything is written so a bad tag leaves the portfolio untouched.
+	var tags *[]models.Tag
+	if updatePayload.Tags != nil {
+		resolvedTags, err := resolveTags(*updatePayload.Tags)
+		if err != nil {
+			utils.JSONResponse(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tags = &resolvedTags
+	}
+
+	err = models.UpdateUserPortfolio(portfolio.UserID, &portfolio, tags)
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio update error", http.StatusInternalServerError)
+		return
+	}
+
+	portfolio, _ = models.GetPortfolio(portfolio.ID)
+	portfolioJson, _ := json.Marshal(portfolio)
+	utils.DSJsonResponse(writer, portfolioJson, http.StatusOK)
+}
+
+// Portfolio godoc
+// @Tags Portfolio
+// @Summary Delete Portfolio
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path string true "Portfolio ID"
+// @Router /api/v1/portfolios/{id} [delete]
+// @Success 200 {object} map[string]interface{}
+// @Failure      404  {object} schemas.ErrorPayload
+func DeletePortfolio(writer http.ResponseWriter, request *http.Request) {
+	portfolio, ok := ownedPortfolio(writer, request)
+	if !ok {
+		return
+	}
+
+	err := models.DeleteUserPortfolio(portfolio.UserID, portfolio.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JSONResponse(writer, "portfolio not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio delete error", http.StatusInternalServerError)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+}
+
+// canDownload decides whether the caller may fetch the clean originals of a portfolio.
+func canDownload(request *http.Request, userID uuid.UUID, portfolio models.Portfolio) bool {
+	if portfolio.UserID == userID || utils.ContextRole(request) == string(models.RoleAdmin) {
+		return true
+	}
+	return portfolio.Price == 0 || models.HasEntitlement(userID, portfolio.ID)
+}
+
+// Portfolio godoc
+// @Tags Portfolio
+// @Summary Download Portfolio Originals
+// @Description Returns short lived signed links to the clean originals of the paywalled images.
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path string true "Portfolio ID"
+// @Router /api/v1/portfolios/{id}/download [get]
+// @Success  200  {object}  []schemas.DownloadURLPayload
+// @Failure      403  {object} schemas.ErrorPayload
+func DownloadPortfolio(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolioID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio not found", http.StatusNotFound)
+		return
+	}
+
+	portfolio, err := models.GetPortfolio(portfolioID)
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio not found", http.StatusNotFound)
+		return
+	}
+
+	if !canDownload(request, userID, portfolio) {
+		utils.JSONResponse(writer, "purchase this portfolio to download it", http.StatusForbidden)
+		return
+	}
+
+	assets, err := models.GetUserPrivateAssetsByURL(portfolio.UserID, portfolio.PaywalledImages)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch portfolio files", http.StatusInternalServerError)
+		return
+	}
+
+	expiry := utils.Settings.DownloadUrlExpiry
+	downloads := []schemas.DownloadURLPayload{}
+	for _, asset := range assets {
+		signedURL, err := storage.Store.PresignGet(request.Context(), asset.StorageKey, expiry)
+		if err != nil {
+			log.Printf("Unable to presign asset %v: %v", asset.ID, err)
+			utils.JSONResponse(writer, "could not create download links", http.StatusInternalServerError)
+			return
+		}
+		downloads = append(downloads, schemas.DownloadURLPayload{
+			AssetID:   asset.ID.String(),
+			FileName:  asset.FileName,
+			URL:       signedURL,
+			ExpiresAt: time.Now().Add(expiry),
+		})
+	}
+
+	downloadsJson, _ := json.Marshal(downloads)
+	writer.Header().Set("Cache-Control", "private, no-store")
+	utils.DSJsonResponse(writer, downloadsJson, http.StatusOK)
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"lightRoom/cache"
+	"lightRoom/models"
+	"lightRoom/privacy"
+	"lightRoom/schemas"
+	"lightRoom/storage"
+	"lightRoom/utils"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxDailyDataExports bounds how many archives one user can have built in a day.
+	maxDailyDataExports = 3
+	// recentLoginWindow is how fresh a session must be to stand in for a password.
+	recentLoginWindow = 10 * time.Minute
+)
+
+// Privacy godoc
+// @Tags Users
+// @Summary Request Data Export
+// @Description Starts building a ZIP of the profile, portfolios, asset metadata, orders and sessions. Poll the export until it is ready.
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/users/me/exports [post]
+// @Success  202  {object} models.DataExport
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+func RequestDataExport(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if models.HasPendingDataExport(userID) {
+		utils.JSONResponse(writer, "an export is already being prepared", http.StatusBadRequest)
+		return
+	}
+	count, retryAfter, err := cache.CountRequest("data-export", userID.String(), 24*time.Hour)
+	if err != nil {
+		utils.JSONResponse(writer, "could not start export", http.StatusInternalServerError)
+		return
+	}
+	if count > maxDailyDataExports {
+		utils.SetRetryAfter(writer, retryAfter)
+		utils.JSONResponse(writer, "too many exports requested, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	export, err := privacy.StartExport(userID)
+	if err != nil {
+		utils.JSONResponse(writer, "could not start export", http.StatusInternalServerError)
+		return
+	}
+	exportJson, _ := json.Marshal(export)
+	utils.DSJsonResponse(writer, exportJson, http.StatusAccepted)
+}
+
+// Privacy godoc
+// @Tags Users
+// @Summary List Data Exports
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/users/me/exports [get]
+// @Success  200  {object} []models.DataExport
+// @Failure      400  {object} schemas.ErrorPayload
+func GetDataExports(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	exports, err := models.GetUserDataExports(userID)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch exports", http.StatusInternalServerError)
+		return
+	}
+	exportsJson, _ := json.Marshal(exports)
+	utils.DSJsonResponse(writer, exportsJson, http.StatusOK)
+}
+
+// Privacy godoc
+// @Tags Users
+// @Summary Get Data Export
+// @Description Once the export is ready the response carries a short lived download link
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Export ID"
+// @Router /api/v1/users/me/exports/{id} [get]
+// @Success  200  {object} schemas.DataExportPayload
+// @Failure      404  {object} schemas.ErrorPayload
+func GetDataExport(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	exportID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "export not found", http.StatusNotFound)
+		return
+	}
+	export, err := models.GetUserDataExport(userID, exportID)
+	if err != nil {
+		utils.JSONResponse(writer, "export not found", http.StatusNotFound)
+		return
+	}
+
+	exportPayload := schemas.DataExportPayload{DataExport: export}
+	if export.Status == models.DataExportReady {
+		exportPayload.DownloadURL, err = storage.Store.PresignGet(request.Context(), export.StorageKey, utils.Settings.DownloadUrlExpiry)
+		if err != nil {
+			utils.JSONResponse(writer, "could not create download link", http.StatusInternalServerError)
+			return
+		}
+	}
+	exportJson, _ := json.Marshal(exportPayload)
+	utils.DSJsonResponse(writer, exportJson, http.StatusOK)
+}
+
+// reauthenticate checks the caller still is the account owner before a destructive change. Accounts
+// made through social login have no password, they give a second factor code or a fresh sign in instead.
+// It writes the error response itself.
+func reauthenticate(writer http.ResponseWriter, request *http.Request, user models.User, password, code string) bool {
+	if !utils.IsUnusablePassword(user.Password) {
+		return checkPassword(writer, request, user, password, "password is incorrect")
+	}
+
+	if code != "" && user.TotpEnabled {
+		return verifySecondFactor(writer, user, code, http.StatusBadRequest)
+	}
+	session, err := cache.GetSession(utils.ContextSessionID(request))
+	if err != nil || time.Since(session.CreatedAt) > recentLoginWindow {
+		utils.JSONResponse(writer, "sign in again to confirm it is you", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// Privacy godoc
+// @Tags Users
+// @Summary Delete Account
+// @Description Schedules the account for deletion after the grace period, signing in or cancelling restores it until then.
+// @Description Accounts without a password confirm with a second factor code, or from a session signed in within the last few minutes.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user body schemas.DeleteAccountPayload true "Delete Account Payload"
+// @Router /api/v1/users/me/deletion [post]
+// @Success  202  {object} schemas.AccountDeletionPayload
+// @Failure      400  {object} schemas.ErrorPayload
+// @Failure      429  {object} schemas.ErrorPayload
+func ScheduleAccountDeletion(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var deleteAccountPayload schemas.DeleteAccountPayload
+
+	err := json.Unmarshal(body, &deleteAccountPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(deleteAccountPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !reauthenticate(writer, request, user, deleteAccountPayload.Password, deleteAccountPayload.Code) {
+		return
+	}
+
+	deleteAt := time.Now().Add(utils.Settings.AccountDeletionGrace)
+	err = models.ScheduleUserDeletion(user.ID, deleteAt)
+	if err != nil {
+		utils.JSONResponse(writer, "could not schedule deletion", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Name     string
+		DeleteAt string
+	}{
+		Name:     user.Name,
+		DeleteAt: deleteAt.UTC().Format("2 January 2006 15:04 MST"),
+	}
+	utils.SendTemplateMail([]string{user.Email}, "Your LightRoom account will be deleted", "templates/account_deletion_email.html", data)
+
+	deletionJson, _ := json.Marshal(schemas.AccountDeletionPayload{DeletionScheduledAt: deleteAt})
+	utils.DSJsonResponse(writer, deletionJson, http.StatusAccepted)
+}
+
+// Privacy godoc
+// @Tags Users
+// @Summary Cancel Account Deletion
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/users/me/deletion [delete]
+// @Success 200 {object} map[string]interface{}
+// @Failure      400  {object} schemas.ErrorPayload
+func CancelAccountDeletion(writer http.ResponseWriter, request *http.Request) {
+	user, ok := contextUser(writer, request)
+	if !ok {
+		return
+	}
+	if user.DeletionScheduledAt == nil {
+		utils.JSONResponse(writer, "account is not scheduled for deletion", http.StatusBadRequest)
+		return
+	}
+
+	err := models.CancelUserDeletion(user.ID)
+	if err != nil {
+		utils.JSONResponse(writer, "could not cancel deletion", http.StatusInternalServerError)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi"
+	"lightRoom/cache"
+	"lightRoom/utils"
+	"net/http"
+)
+
+// Sessions godoc
+// @Tags Auth
+// @Summary List Sessions
+// @Description Devices currently signed in to the account
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/auth/sessions [get]
+// @Success  200  {object} []cache.Session
+// @Failure      400  {object} schemas.ErrorPayload
+func GetSessions(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := cache.GetUserSessions(userID.String())
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch sessions", http.StatusInternalServerError)
+		return
+	}
+	currentSessionID := utils.ContextSessionID(request)
+	for index := range sessions {
+		sessions[index].Current = sessions[index].ID == currentSessionID
+	}
+
+	sessionsJson, _ := json.Marshal(sessions)
+	utils.DSJsonResponse(writer, sessionsJson, http.StatusOK)
+}
+
+// Sessions godoc
+// @Tags Auth
+// @Summary Revoke Session
+// @Description Signs a single device out, its access and refresh tokens stop working at once
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Session ID"
+// @Router /api/v1/auth/sessions/{id} [delete]
+// @Success 200 {object} map[string]interface{}
+// @Failure      404  {object} schemas.ErrorPayload
+func RevokeSession(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := cache.GetSession(chi.URLParam(request, "id"))
+	if err != nil || session.UserID != userID.String() {
+		utils.JSONResponse(writer, "session not found", http.StatusNotFound)
+		return
+	}
+
+	err = cache.DeleteSession(session.UserID, session.ID)
+	if err != nil {
+		utils.JSONResponse(writer, "could not revoke session", http.StatusInternalServerError)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+}
+
+// Sessions godoc
+// @Tags Auth
+// @Summary Log Out Everywhere
+// @Description Revokes every session of the account, including the current one
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/auth/sessions [delete]
+// @Success 200 {object} map[string]interface{}
+// @Failure      400  {object} schemas.ErrorPayload
+func RevokeAllSessions(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = cache.DeleteUserSessions(userID.String())
+	if err != nil {
+		utils.JSONResponse(writer, "could not revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/utils"
+	"net/http"
+	"strings"
+)
+
+// Tag godoc
+// @Tags Tag
+// @Summary List Tags
+// @Produce json
+// @Param title query string false "Search tags by title"
+// @Router /api/v1/tags [get]
+// @Success  200  {object}  []models.Tag
+// @Failure      400  {object} schemas.ErrorPayload
+func GetTags(writer http.ResponseWriter, request *http.Request) {
+	tags, err := models.GetTags(request.URL.Query().Get("title"))
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch tags", http.StatusInternalServerError)
+		return
+	}
+
+	tagsJson, _ := json.Marshal(tags)
+	utils.DSJsonResponse(writer, tagsJson, http.StatusOK)
+}
+
+// Tag godoc
+// @Tags Tag
+// @Summary Get Tag
+// @Produce json
+// @Param id path string true "Tag ID"
+// @Router /api/v1/tags/{id} [get]
+// @Success  200  {object}  models.Tag
+// @Failure      404  {object} schemas.ErrorPayload
+func GetTag(writer http.ResponseWriter, request *http.Request) {
+	tagID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "tag not found", http.StatusNotFound)
+		return
+	}
+
+	tag, err := models.GetTag(tagID)
+	if err != nil {
+		utils.JSONResponse(writer, "tag not found", http.StatusNotFound)
+		return
+	}
+
+	tagJson, _ := json.Marshal(tag)
+	utils.DSJsonResponse(writer, tagJson, http.StatusOK)
+}
+
+// Tag godoc
+// @Tags Tag
+// @Summary Create Tag
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param tag body schemas.TagPayload true "Create Tag Payload"
+// @Router /api/v1/tags [post]
+// @Success  201  {object}  models.Tag
+// @Failure      400  {object} schemas.ErrorPayload
+func CreateTag(writer http.ResponseWriter, request *http.Request) {
+	body, _ := ioutil.ReadAll(request.Body)
+	var tagPayload schemas.TagPayload
+
+	err := json.Unmarshal(body, &tagPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "tag body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	tagPayload.Title = strings.TrimSpace(tagPayload.Title)
+	err = validate.Struct(tagPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err = models.GetTagByTitle(tagPayload.Title)
+	if err == nil {
+		utils.JSONResponse(writer, "tag already exist", http.StatusBadRequest)
+		return
+	}
+
+	tag := models.Tag{
+		ID:    uuid.New(),
+		Title: tagPayload.Title,
+	}
+	err = models.CreateTag(tag)
+	if err != nil {
+		utils.JSONResponse(writer, "tag creation error", http.StatusInternalServerError)
+		return
+	}
+
+	tagJson, _ := json.Marshal(tag)
+	utils.DSJsonResponse(writer, tagJson, http.StatusCreated)
+}
+
+// Portfolio godoc
+// @Tags Portfolio
+// @Summary Tag Portfolio
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path string true "Portfolio ID"
+// @Param tags body schemas.PortfolioTagsPayload true "Portfolio Tags Payload"
+// @Router /api/v1/portfolios/{id}/tags [post]
+// @Success  200  {object}  models.Portfolio
+// @Failure      400  {object} schemas.ErrorPayload
+func AddPortfolioTags(writer http.ResponseWriter, request *http.Request) {
+	portfolio, ok := ownedPortfolio(writer, request)
+	if !ok {
+		return
+	}
+
+	body, _ := ioutil.ReadAll(request.Body)
+	var tagsPayload schemas.PortfolioTagsPayload
+
+	err := json.Unmarshal(body, &tagsPayload)
+	if err != nil {
+		utils.JSONResponse(writer, "tags body not valid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = validate.Struct(tagsPayload)
+	if err != nil {
+		validationError := err.(validator.ValidationErrors)
+		utils.JSONResponse(writer, validationError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tags, err := resolveTags(tagsPayload.Tags)
+	if err != nil {
+		utils.JSONResponse(writer, "one or more tags do not exist", http.StatusBadRequest)
+		return
+	}
+
+	err = models.AddPortfolioTags(&portfolio, tags)
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio tag update error", http.StatusInternalServerError)
+		return
+	}
+
+	portfolio, _ = models.GetPortfolio(portfolio.ID)
+	portfolioJson, _ := json.Marshal(portfolio)
+	utils.DSJsonResponse(writer, portfolioJson, http.StatusOK)
+}
+
+// Portfolio godoc
+// @Tags Portfolio
+// @Summary Untag Portfolio
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path string true "Portfolio ID"
+// @Param tagID path string true "Tag ID"
+// @Router /api/v1/portfolios/{id}/tags/{tagID} [delete]
+// @Success  200  {object}  models.Portfolio
+// @Failure      404  {object} schemas.ErrorPayload
+func RemovePortfolioTag(writer http.ResponseWriter, request *http.Request) {
+	portfolio, ok := ownedPortfolio(writer, request)
+	if !ok {
+		return
+	}
+
+	tagID, err := uuid.Parse(chi.URLParam(request, "tagID"))
+	if err != nil {
+		utils.JSONResponse(writer, "tag not found", http.StatusNotFound)
+		return
+	}
+
+	tag, err := models.GetTag(tagID)
+	if err != nil {
+		utils.JSONResponse(writer, "tag not found", http.StatusNotFound)
+		return
+	}
+
+	err = models.RemovePortfolioTags(&portfolio, []models.Tag{tag})
+	if err != nil {
+		utils.JSONResponse(writer, "portfolio tag update error", http.StatusInternalServerError)
+		return
+	}
+
+	portfolio, _ = models.GetPortfolio(portfolio.ID)
+	portfolioJson, _ := json.Marshal(portfolio)
+	utils.DSJsonResponse(writer, portfolioJson, http.StatusOK)
+}
@@ -0,0 +1,308 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"lightRoom/cache"
+	"lightRoom/models"
+	"lightRoom/schemas"
+	"lightRoom/utils"
+	"log"
+	"net/http"
+)
+
+// webAuthnUser adapts a user and their stored credentials to webauthn.User.
+type webAuthnUser struct {
+	user        models.User
+	credentials []models.WebAuthnCredential
+}
+
+func (u webAuthnUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.credentials))
+	for index, credential := range u.credentials {
+		credentials[index] = credential.Credential
+	}
+	return credentials
+}
+
+func loadWebAuthnUser(userID uuid.UUID) (webAuthnUser, error) {
+	user, err := models.GetUser(userID)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+	credentials, err := models.GetUserWebAuthnCredentials(userID)
+	return webAuthnUser{user: user, credentials: credentials}, err
+}
+
+// startCeremony keeps the session data in Redis and writes the options for the browser.
+func startCeremony(writer http.ResponseWriter, options interface{}, sessionData *webauthn.SessionData) {
+	ceremonyID := utils.SecureToken(32)
+	sessionJson, _ := json.Marshal(sessionData)
+	err := cache.SetWebAuthnCeremony(ceremonyID, sessionJson)
+	if err != nil {
+		utils.JSONResponse(writer, "could not start ceremony", http.StatusInternalServerError)
+		return
+	}
+
+	optionsJson, _ := json.Marshal(options)
+	ceremonyJson, _ := json.Marshal(schemas.WebAuthnCeremonyPayload{CeremonyID: ceremonyID, Options: optionsJson})
+	utils.DSJsonResponse(writer, ceremonyJson, http.StatusOK)
+}
+
+// takeCeremony loads the session data named by the ceremony_id query parameter, once.
+func takeCeremony(writer http.ResponseWriter, request *http.Request) (webauthn.SessionData, bool) {
+	var sessionData webauthn.SessionData
+	sessionJson, err := cache.TakeWebAuthnCeremony(request.URL.Query().Get("ceremony_id"))
+	if err == nil {
+		err = json.Unmarshal(sessionJson, &sessionData)
+	}
+	if err != nil {
+		utils.JSONResponse(writer, "ceremony has expired, start again", http.StatusBadRequest)
+		return sessionData, false
+	}
+	return sessionData, true
+}
+
+// WebAuthn godoc
+// @Tags WebAuthn
+// @Summary Begin Passkey Registration
+// @Description Returns the options to pass to navigator.credentials.create()
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/auth/webauthn/register/begin [post]
+// @Success  200  {object} schemas.WebAuthnCeremonyPayload
+// @Failure      400  {object} schemas.ErrorPayload
+func BeginWebAuthnRegistration(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, err := loadWebAuthnUser(userID)
+	if err != nil {
+		utils.JSONResponse(writer, "user not found", http.StatusNotFound)
+		return
+	}
+
+	// Registered credentials are excluded so the same authenticator is not added twice.
+	exclusions := make([]protocol.CredentialDescriptor, len(user.credentials))
+	for index, credential := range user.credentials {
+		exclusions[index] = credential.Credential.Descriptor()
+	}
+	options, sessionData, err := utils.WebAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		utils.JSONResponse(writer, "could not start registration", http.StatusInternalServerError)
+		return
+	}
+	startCeremony(writer, options, sessionData)
+}
+
+// WebAuthn godoc
+// @Tags WebAuthn
+// @Summary Finish Passkey Registration
+// @Description Takes the PublicKeyCredential from navigator.credentials.create() as the body
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param ceremony_id query string true "Ceremony ID from the begin step"
+// @Param name query string false "Label for the credential"
+// @Router /api/v1/auth/webauthn/register/finish [post]
+// @Success  201  {object} models.WebAuthnCredential
+// @Failure      400  {object} schemas.ErrorPayload
+func FinishWebAuthnRegistration(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sessionData, ok := takeCeremony(writer, request)
+	if !ok {
+		return
+	}
+	user, err := loadWebAuthnUser(userID)
+	if err != nil {
+		utils.JSONResponse(writer, "user not found", http.StatusNotFound)
+		return
+	}
+
+	credential, err := utils.WebAuthn.FinishRegistration(user, sessionData, request)
+	if err != nil {
+		log.Printf("WebAuthn registration failed for %v: %v", userID, err)
+		utils.JSONResponse(writer, "credential could not be verified", http.StatusBadRequest)
+		return
+	}
+
+	name := request.URL.Query().Get("name")
+	if name == "" || len(name) > 64 {
+		name = utils.DeviceName(request.UserAgent())
+	}
+	webAuthnCredential := models.WebAuthnCredential{
+		ID:           uuid.New(),
+		UserID:       userID,
+		Name:         name,
+		CredentialID: credential.ID,
+		Credential:   *credential,
+	}
+	err = models.CreateWebAuthnCredential(webAuthnCredential)
+	if err != nil {
+		utils.JSONResponse(writer, "credential is already registered", http.StatusConflict)
+		return
+	}
+
+	credentialJson, _ := json.Marshal(webAuthnCredential)
+	utils.DSJsonResponse(writer, credentialJson, http.StatusCreated)
+}
+
+// WebAuthn godoc
+// @Tags WebAuthn
+// @Summary Begin Passkey Login
+// @Description Returns the options to pass to navigator.credentials.get(), the passkey identifies the user
+// @Produce json
+// @Router /api/v1/auth/webauthn/login/begin [post]
+// @Success  200  {object} schemas.WebAuthnCeremonyPayload
+// @Failure      400  {object} schemas.ErrorPayload
+func BeginWebAuthnLogin(writer http.ResponseWriter, request *http.Request) {
+	options, sessionData, err := utils.WebAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		utils.JSONResponse(writer, "could not start login", http.StatusInternalServerError)
+		return
+	}
+	startCeremony(writer, options, sessionData)
+}
+
+// WebAuthn godoc
+// @Tags WebAuthn
+// @Summary Finish Passkey Login
+// @Description Takes the PublicKeyCredential from navigator.credentials.get() as the body
+// @Accept json
+// @Produce json
+// @Param ceremony_id query string true "Ceremony ID from the begin step"
+// @Router /api/v1/auth/webauthn/login/finish [post]
+// @Success  200  {object}  schemas.AccessPayload
+// @Failure      401  {object} schemas.ErrorPayload
+func FinishWebAuthnLogin(writer http.ResponseWriter, request *http.Request) {
+	sessionData, ok := takeCeremony(writer, request)
+	if !ok {
+		return
+	}
+
+	var storedCredential models.WebAuthnCredential
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		var err error
+		storedCredential, err = models.GetWebAuthnCredentialByCredentialID(rawID)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(storedCredential.UserID[:], userHandle) {
+			return nil, errors.New("credential does not belong to this user")
+		}
+		return loadWebAuthnUser(storedCredential.UserID)
+	}
+
+	parsedResponse, err := protocol.ParseCredentialRequestResponse(request)
+	if err != nil {
+		utils.JSONResponse(writer, "credential could not be verified", http.StatusBadRequest)
+		return
+	}
+	passkeyUser, credential, err := utils.WebAuthn.ValidatePasskeyLogin(findUser, sessionData, parsedResponse)
+	if err != nil {
+		utils.JSONResponse(writer, "credential could not be verified", http.StatusUnauthorized)
+		return
+	}
+	// A counter that went backwards means the key may have been copied.
+	if credential.Authenticator.CloneWarning {
+		log.Printf("WebAuthn clone warning for credential %v", storedCredential.ID)
+		utils.JSONResponse(writer, "credential could not be verified", http.StatusUnauthorized)
+		return
+	}
+	_ = models.UpdateWebAuthnCredentialUse(storedCredential.ID, *credential)
+
+	user := passkeyUser.(webAuthnUser).user
+	if !user.IsVerified {
+		utils.JSONResponse(writer, "user account is not verified", http.StatusBadRequest)
+		return
+	}
+	if user.IsSuspended {
+		utils.JSONResponse(writer, "user account is suspended", http.StatusForbidden)
+		return
+	}
+	// A verified passkey is already two factors, so no TOTP challenge follows.
+	issueSession(writer, request, user)
+}
+
+// WebAuthn godoc
+// @Tags WebAuthn
+// @Summary List Passkeys
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/auth/webauthn/credentials [get]
+// @Success  200  {object} []models.WebAuthnCredential
+// @Failure      400  {object} schemas.ErrorPayload
+func GetWebAuthnCredentials(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	credentials, err := models.GetUserWebAuthnCredentials(userID)
+	if err != nil {
+		utils.JSONResponse(writer, "could not fetch credentials", http.StatusInternalServerError)
+		return
+	}
+
+	credentialsJson, _ := json.Marshal(credentials)
+	utils.DSJsonResponse(writer, credentialsJson, http.StatusOK)
+}
+
+// WebAuthn godoc
+// @Tags WebAuthn
+// @Summary Delete Passkey
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Credential ID"
+// @Router /api/v1/auth/webauthn/credentials/{id} [delete]
+// @Success 200 {object} map[string]interface{}
+// @Failure      404  {object} schemas.ErrorPayload
+func DeleteWebAuthnCredential(writer http.ResponseWriter, request *http.Request) {
+	userID, err := utils.ContextUserID(request)
+	if err != nil {
+		utils.JSONResponse(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	credentialID, err := uuid.Parse(chi.URLParam(request, "id"))
+	if err != nil {
+		utils.JSONResponse(writer, "credential not found", http.StatusNotFound)
+		return
+	}
+
+	deleted, err := models.DeleteUserWebAuthnCredential(userID, credentialID)
+	if err != nil || !deleted {
+		utils.JSONResponse(writer, "credential not found", http.StatusNotFound)
+		return
+	}
+	utils.DSJsonResponse(writer, []byte(`{}`), http.StatusOK)
+}
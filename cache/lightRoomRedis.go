@@ -2,33 +2,50 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"time"
 )
 
 var contxt = context.Background()
 
-func verificationTokenGenerator(token string) string {
+func verificationTokenGenerator(tokenHash string) string {
 
-	return fmt.Sprintf("light-room-user-verification-%v", token)
+	return fmt.Sprintf("light-room-user-verification-%v", tokenHash)
 }
 
-func SetUserVerificationToken(userId uuid.UUID, token string) {
-	key := verificationTokenGenerator(token)
-	_ = LRedis.Set(
-		contxt, key, userId.String(), 24*time.Hour,
+func currentVerificationKey(userId string) string {
+	return fmt.Sprintf("light-room-user-verification-current-%v", userId)
+}
+
+// SetUserVerificationToken stores a verification token by its hash, so a leaked Redis dump holds no usable links.
+// Only the newest token of a user works, issuing one invalidates the one before.
+func SetUserVerificationToken(userId uuid.UUID, tokenHash string, expiry time.Duration) error {
+	previous, err := LRedis.SetArgs(contxt, currentVerificationKey(userId.String()), tokenHash, redis.SetArgs{Get: true, TTL: expiry}).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	if previous != "" {
+		LRedis.Del(contxt, verificationTokenGenerator(previous))
+	}
+
+	key := verificationTokenGenerator(tokenHash)
+	return LRedis.Set(
+		contxt, key, userId.String(), expiry,
 	).Err()
 
 }
 
-func GetUserVerificationToken(token string) (string, error) {
-	key := verificationTokenGenerator(token)
-	return LRedis.Get(contxt, key).Result()
+// TakeUserVerificationToken returns the user a verification token was issued to and consumes the token.
+func TakeUserVerificationToken(tokenHash string) (string, error) {
+	key := verificationTokenGenerator(tokenHash)
+	return LRedis.GetDel(contxt, key).Result()
 }
 
-func DeleteUserVerificationToken(token string) error {
-	key := verificationTokenGenerator(token)
+func DeleteUserVerificationToken(tokenHash string) error {
+	key := verificationTokenGenerator(tokenHash)
 	return LRedis.Del(contxt, key).Err()
 }
 
@@ -36,10 +53,11 @@ func TokenKeyGeneration(token string) string {
 	return fmt.Sprintf("light-room-token-%v", token)
 }
 
-func SetToken(token string) {
+// SetToken blacklists a token, expiry should be at least the token's remaining lifetime.
+func SetToken(token string, expiry time.Duration) {
 	key := TokenKeyGeneration(token)
 
-	_ = LRedis.Set(contxt, key, token, 48*time.Hour).Err()
+	_ = LRedis.Set(contxt, key, token, expiry).Err()
 }
 
 func GetToken(token string) (string, error) {
@@ -47,17 +65,221 @@ func GetToken(token string) (string, error) {
 	return LRedis.Get(contxt, key).Result()
 }
 
-func PasswordResetKey(token string) string {
-	return fmt.Sprintf("light-room-password-reset-%v", token)
+func PasswordResetKey(tokenHash string) string {
+	return fmt.Sprintf("light-room-password-reset-%v", tokenHash)
 }
 
-func SetPasswordToken(token string, userId uuid.UUID) {
-	key := PasswordResetKey(token)
-	_ = LRedis.Set(contxt, key, userId.String(), 15*time.Minute).Err()
+// SetPasswordToken stores a reset token by its hash, like SetUserVerificationToken.
+func SetPasswordToken(tokenHash string, userId uuid.UUID, expiry time.Duration) error {
+	key := PasswordResetKey(tokenHash)
+	return LRedis.Set(contxt, key, userId.String(), expiry).Err()
 
 }
 
-func GetPasswordToken(token string) (string, error) {
-	key := PasswordResetKey(token)
+func GetPasswordToken(tokenHash string) (string, error) {
+	key := PasswordResetKey(tokenHash)
 	return LRedis.Get(contxt, key).Result()
 }
+
+// TakePasswordToken returns the user a reset token was issued to and consumes the token.
+func TakePasswordToken(tokenHash string) (string, error) {
+	key := PasswordResetKey(tokenHash)
+	return LRedis.GetDel(contxt, key).Result()
+}
+
+func suspendedUserKey(userId string) string {
+	return fmt.Sprintf("light-room-suspended-user-%v", userId)
+}
+
+// SetUserSuspended marks a user as suspended until UnsetUserSuspended is called,
+// so their outstanding tokens stop working straight away.
+func SetUserSuspended(userId uuid.UUID) error {
+	key := suspendedUserKey(userId.String())
+	return LRedis.Set(contxt, key, userId.String(), 0).Err()
+}
+
+func UnsetUserSuspended(userId uuid.UUID) error {
+	key := suspendedUserKey(userId.String())
+	return LRedis.Del(contxt, key).Err()
+}
+
+func IsUserSuspended(userId string) (bool, error) {
+	key := suspendedUserKey(userId)
+	count, err := LRedis.Exists(contxt, key).Result()
+	return count > 0, err
+}
+
+func totpEnrollmentKey(userId string) string {
+	return fmt.Sprintf("light-room-totp-enrollment-%v", userId)
+}
+
+// SetTotpEnrollment holds a secret the user has not confirmed with a code yet.
+func SetTotpEnrollment(userId uuid.UUID, secret string) error {
+	key := totpEnrollmentKey(userId.String())
+	return LRedis.Set(contxt, key, secret, 15*time.Minute).Err()
+}
+
+func GetTotpEnrollment(userId uuid.UUID) (string, error) {
+	key := totpEnrollmentKey(userId.String())
+	return LRedis.Get(contxt, key).Result()
+}
+
+func DeleteTotpEnrollment(userId uuid.UUID) error {
+	key := totpEnrollmentKey(userId.String())
+	return LRedis.Del(contxt, key).Err()
+}
+
+func mfaChallengeKey(token string) string {
+	return fmt.Sprintf("light-room-mfa-challenge-%v", token)
+}
+
+func mfaAttemptsKey(token string) string {
+	return fmt.Sprintf("light-room-mfa-attempts-%v", token)
+}
+
+// SetMfaChallenge remembers that the user behind token passed the password check.
+func SetMfaChallenge(token string, userId uuid.UUID, expiry time.Duration) error {
+	key := mfaChallengeKey(token)
+	return LRedis.Set(contxt, key, userId.String(), expiry).Err()
+}
+
+func GetMfaChallenge(token string) (string, error) {
+	key := mfaChallengeKey(token)
+	return LRedis.Get(contxt, key).Result()
+}
+
+// IncrMfaChallengeAttempts counts the codes tried against a challenge.
+func IncrMfaChallengeAttempts(token string, expiry time.Duration) (int64, error) {
+	key := mfaAttemptsKey(token)
+	attempts, err := LRedis.Incr(contxt, key).Result()
+	if err == nil && attempts == 1 {
+		LRedis.Expire(contxt, key, expiry)
+	}
+	return attempts, err
+}
+
+func DeleteMfaChallenge(token string) error {
+	return LRedis.Del(contxt, mfaChallengeKey(token), mfaAttemptsKey(token)).Err()
+}
+
+func webAuthnCeremonyKey(ceremonyID string) string {
+	return fmt.Sprintf("light-room-webauthn-ceremony-%v", ceremonyID)
+}
+
+// SetWebAuthnCeremony holds the challenge of a WebAuthn registration or login in progress.
+func SetWebAuthnCeremony(ceremonyID string, sessionData []byte) error {
+	key := webAuthnCeremonyKey(ceremonyID)
+	return LRedis.Set(contxt, key, sessionData, 5*time.Minute).Err()
+}
+
+// TakeWebAuthnCeremony returns the ceremony's challenge and forgets it, so it can only be answered once.
+func TakeWebAuthnCeremony(ceremonyID string) ([]byte, error) {
+	key := webAuthnCeremonyKey(ceremonyID)
+	return LRedis.GetDel(contxt, key).Bytes()
+}
+
+func oauthStateKey(state string) string {
+	return fmt.Sprintf("light-room-oauth-state-%v", state)
+}
+
+// SetOAuthState holds the provider, PKCE verifier and nonce of a social login until the callback.
+func SetOAuthState(state string, flow []byte) error {
+	key := oauthStateKey(state)
+	return LRedis.Set(contxt, key, flow, 10*time.Minute).Err()
+}
+
+// TakeOAuthState returns the login started with state and forgets it, so a callback works once.
+func TakeOAuthState(state string) ([]byte, error) {
+	key := oauthStateKey(state)
+	return LRedis.GetDel(contxt, key).Bytes()
+}
+
+func emailChangeKey(tokenHash string) string {
+	return fmt.Sprintf("light-room-email-change-%v", tokenHash)
+}
+
+func SetEmailChange(tokenHash string, change []byte, expiry time.Duration) error {
+	key := emailChangeKey(tokenHash)
+	return LRedis.Set(contxt, key, change, expiry).Err()
+}
+
+// TakeEmailChange returns a pending email change and consumes its token.
+func TakeEmailChange(tokenHash string) ([]byte, error) {
+	key := emailChangeKey(tokenHash)
+	return LRedis.GetDel(contxt, key).Bytes()
+}
+
+func rateLimitKey(scope, subject string) string {
+	return fmt.Sprintf("light-room-rate-%v-%v", scope, subject)
+}
+
+// CountRequest records one request by subject in a fixed window and returns how many
+// have been made so far along with the time left until the window resets.
+func CountRequest(scope, subject string, window time.Duration) (int64, time.Duration, error) {
+	key := rateLimitKey(scope, subject)
+	count, err := LRedis.Incr(contxt, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	if count == 1 {
+		LRedis.Expire(contxt, key, window)
+		return count, window, nil
+	}
+	ttl, err := LRedis.TTL(contxt, key).Result()
+	if err == nil && ttl < 0 {
+		// The expiry was lost, most likely to a crash between INCR and EXPIRE.
+		LRedis.Expire(contxt, key, window)
+		ttl = window
+	}
+	return count, ttl, err
+}
+
+func magicLinkKey(tokenHash string) string {
+	return fmt.Sprintf("light-room-magic-link-%v", tokenHash)
+}
+
+func SetMagicLinkToken(tokenHash string, userId uuid.UUID, expiry time.Duration) error {
+	key := magicLinkKey(tokenHash)
+	return LRedis.Set(contxt, key, userId.String(), expiry).Err()
+}
+
+// TakeMagicLinkToken returns the user a link was issued to and consumes the link.
+func TakeMagicLinkToken(tokenHash string) (string, error) {
+	key := magicLinkKey(tokenHash)
+	return LRedis.GetDel(contxt, key).Result()
+}
+
+func loginFailuresKey(scope, subject string) string {
+	return fmt.Sprintf("light-room-login-failures-%v-%v", scope, subject)
+}
+
+func loginBlockKey(scope, subject string) string {
+	return fmt.Sprintf("light-room-login-block-%v-%v", scope, subject)
+}
+
+// IncrLoginFailures counts a failed login, the count is forgotten after window without failures.
+func IncrLoginFailures(scope, subject string, window time.Duration) (int64, error) {
+	key := loginFailuresKey(scope, subject)
+	pipe := LRedis.TxPipeline()
+	count := pipe.Incr(contxt, key)
+	pipe.Expire(contxt, key, window)
+	_, err := pipe.Exec(contxt)
+	return count.Val(), err
+}
+
+func ClearLoginFailures(scope, subject string) error {
+	return LRedis.Del(contxt, loginFailuresKey(scope, subject), loginBlockKey(scope, subject)).Err()
+}
+
+func BlockLogin(scope, subject string, duration time.Duration) error {
+	return LRedis.Set(contxt, loginBlockKey(scope, subject), "1", duration).Err()
+}
+
+// LoginBlockedFor returns how much longer logins for subject are refused, zero when they are not.
+func LoginBlockedFor(scope, subject string) time.Duration {
+	ttl, err := LRedis.PTTL(contxt, loginBlockKey(scope, subject)).Result()
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
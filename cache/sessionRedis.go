@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+	ErrSessionNotFound    = errors.New("session has expired or was revoked")
+)
+
+// Session is one signed-in device. Its ID is carried by every token issued for it,
+// so deleting the session revokes those tokens.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"-"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+func sessionKey(sessionID string) string {
+	return fmt.Sprintf("light-room-session-%v", sessionID)
+}
+
+func userSessionsKey(userId string) string {
+	return fmt.Sprintf("light-room-user-sessions-%v", userId)
+}
+
+// CreateSession stores the session with refreshTokenID as its only usable refresh token.
+func CreateSession(session Session, refreshTokenID string, expiry time.Duration) error {
+	key := sessionKey(session.ID)
+	_, err := LRedis.TxPipelined(contxt, func(pipe redis.Pipeliner) error {
+		pipe.HSet(contxt, key,
+			"user_id", session.UserID,
+			"device", session.Device,
+			"ip", session.IP,
+			"user_agent", session.UserAgent,
+			"created_at", session.CreatedAt.Unix(),
+			"last_seen_at", session.LastSeenAt.Unix(),
+			"refresh_token_id", refreshTokenID,
+		)
+		pipe.Expire(contxt, key, expiry)
+		pipe.SAdd(contxt, userSessionsKey(session.UserID), session.ID)
+		pipe.Expire(contxt, userSessionsKey(session.UserID), expiry)
+		return nil
+	})
+	return err
+}
+
+// rotateSessionScript swaps the session's refresh token for the next one in a single step,
+// so two requests racing with the same token cannot both succeed. A token that is not
+// the current one has been rotated already and the whole session is dropped.
+// The user's session set (KEYS[2]) is kept alive at least as long as the session, otherwise
+// revoking every session would miss the ones kept going by refreshes.
+var rotateSessionScript = redis.NewScript(`
+local current = redis.call("HGET", KEYS[1], "refresh_token_id")
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	redis.call("DEL", KEYS[1])
+	return -1
+end
+redis.call("HSET", KEYS[1], "refresh_token_id", ARGV[2], "ip", ARGV[4], "last_seen_at", ARGV[5])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+redis.call("SADD", KEYS[2], ARGV[6])
+if redis.call("PTTL", KEYS[2]) < tonumber(ARGV[3]) then
+	redis.call("PEXPIRE", KEYS[2], ARGV[3])
+end
+return 1
+`)
+
+// RotateSessionToken replaces tokenID with nextTokenID as the session's usable refresh token.
+// Presenting a token other than the current one revokes the session.
+func RotateSessionToken(userId, sessionID, tokenID, nextTokenID, ip string, expiry time.Duration) error {
+	key := sessionKey(sessionID)
+	result, err := rotateSessionScript.Run(contxt, LRedis, []string{key, userSessionsKey(userId)},
+		tokenID, nextTokenID, expiry.Milliseconds(), ip, time.Now().Unix(), sessionID).Int()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case 0:
+		return ErrSessionNotFound
+	case -1:
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+var touchSessionScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], "ip", ARGV[1], "last_seen_at", ARGV[2])
+return 1
+`)
+
+// TouchSession records activity on the session, returning false when it no longer exists.
+func TouchSession(sessionID, ip string) (bool, error) {
+	key := sessionKey(sessionID)
+	result, err := touchSessionScript.Run(contxt, LRedis, []string{key}, ip, time.Now().Unix()).Int()
+	return result == 1, err
+}
+
+func GetSession(sessionID string) (Session, error) {
+	fields, err := LRedis.HGetAll(contxt, sessionKey(sessionID)).Result()
+	if err != nil {
+		return Session{}, err
+	}
+	if len(fields) == 0 {
+		return Session{}, ErrSessionNotFound
+	}
+	createdAt, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+	lastSeenAt, _ := strconv.ParseInt(fields["last_seen_at"], 10, 64)
+	return Session{
+		ID:         sessionID,
+		UserID:     fields["user_id"],
+		Device:     fields["device"],
+		IP:         fields["ip"],
+		UserAgent:  fields["user_agent"],
+		CreatedAt:  time.Unix(createdAt, 0).UTC(),
+		LastSeenAt: time.Unix(lastSeenAt, 0).UTC(),
+	}, nil
+}
+
+// GetUserSessions lists the user's live sessions, forgetting the ones that have expired.
+func GetUserSessions(userId string) ([]Session, error) {
+	sessionIDs, err := LRedis.SMembers(contxt, userSessionsKey(userId)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []Session{}
+	for _, sessionID := range sessionIDs {
+		session, err := GetSession(sessionID)
+		if errors.Is(err, ErrSessionNotFound) {
+			LRedis.SRem(contxt, userSessionsKey(userId), sessionID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func DeleteSession(userId, sessionID string) error {
+	_, err := LRedis.TxPipelined(contxt, func(pipe redis.Pipeliner) error {
+		pipe.Del(contxt, sessionKey(sessionID))
+		pipe.SRem(contxt, userSessionsKey(userId), sessionID)
+		return nil
+	})
+	return err
+}
+
+// DeleteUserSessions signs the user out of every device.
+func DeleteUserSessions(userId string) error {
+	sessionIDs, err := LRedis.SMembers(contxt, userSessionsKey(userId)).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{userSessionsKey(userId)}
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, sessionKey(sessionID))
+	}
+	return LRedis.Del(contxt, keys...).Err()
+}
+
+// DeleteOtherUserSessions signs the user out of every device but the one holding keepSessionID.
+func DeleteOtherUserSessions(userId, keepSessionID string) error {
+	sessionIDs, err := LRedis.SMembers(contxt, userSessionsKey(userId)).Result()
+	if err != nil {
+		return err
+	}
+	for _, sessionID := range sessionIDs {
+		if sessionID == keepSessionID {
+			continue
+		}
+		if err = DeleteSession(userId, sessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	redisServer := miniredis.RunT(t)
+	LRedis = redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { LRedis.Close() })
+	return redisServer
+}
+
+func createTestSession(t *testing.T, userID, tokenID string, expiry time.Duration) Session {
+	t.Helper()
+	session := Session{ID: uuid.NewString(), UserID: userID, Device: "test", CreatedAt: time.Now(), LastSeenAt: time.Now()}
+	if err := CreateSession(session, tokenID, expiry); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return session
+}
+
+func TestRotateSessionTokenRefusesReuse(t *testing.T) {
+	useMiniredis(t)
+	userID := uuid.NewString()
+	session := createTestSession(t, userID, "first", time.Hour)
+
+	if err := RotateSessionToken(userID, session.ID, "first", "second", "127.0.0.1", time.Hour); err != nil {
+		t.Fatalf("RotateSessionToken: %v", err)
+	}
+	if err := RotateSessionToken(userID, session.ID, "first", "third", "127.0.0.1", time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reusing a rotated token: err = %v, want ErrRefreshTokenReused", err)
+	}
+	// Reuse revokes the session, the token it was rotated to stops working too.
+	if err := RotateSessionToken(userID, session.ID, "second", "third", "127.0.0.1", time.Hour); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("rotating after reuse: err = %v, want ErrSessionNotFound", err)
+	}
+}
+
+// A session kept alive by refreshes must stay reachable through the user's session set
+// after the TTL the set was given at login has passed.
+func TestRotatedSessionOutlivingLoginIsStillRevoked(t *testing.T) {
+	redisServer := useMiniredis(t)
+	userID := uuid.NewString()
+	refreshed := createTestSession(t, userID, "token-0", time.Hour)
+	idle := createTestSession(t, userID, "idle", time.Hour)
+
+	for step := 1; step <= 3; step++ {
+		redisServer.FastForward(50 * time.Minute)
+		previous, next := "token-"+strconv.Itoa(step-1), "token-"+strconv.Itoa(step)
+		if err := RotateSessionToken(userID, refreshed.ID, previous, next, "127.0.0.1", time.Hour); err != nil {
+			t.Fatalf("refresh %d: %v", step, err)
+		}
+	}
+
+	sessions, err := GetUserSessions(userID)
+	if err != nil {
+		t.Fatalf("GetUserSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != refreshed.ID {
+		t.Fatalf("GetUserSessions = %+v, want only the refreshed session", sessions)
+	}
+
+	if err = DeleteUserSessions(userID); err != nil {
+		t.Fatalf("DeleteUserSessions: %v", err)
+	}
+	if _, err = GetSession(refreshed.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("the refreshed session survived DeleteUserSessions: err = %v", err)
+	}
+	if _, err = GetSession(idle.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("the idle session outlived its expiry: err = %v", err)
+	}
+}
+
+func TestDeleteOtherUserSessionsAfterRotation(t *testing.T) {
+	redisServer := useMiniredis(t)
+	userID := uuid.NewString()
+	kept := createTestSession(t, userID, "kept", time.Hour)
+	other := createTestSession(t, userID, "other-0", time.Hour)
+
+	redisServer.FastForward(50 * time.Minute)
+	if err := RotateSessionToken(userID, other.ID, "other-0", "other-1", "127.0.0.1", time.Hour); err != nil {
+		t.Fatalf("RotateSessionToken: %v", err)
+	}
+	redisServer.FastForward(50 * time.Minute)
+
+	if err := DeleteOtherUserSessions(userID, kept.ID); err != nil {
+		t.Fatalf("DeleteOtherUserSessions: %v", err)
+	}
+	if _, err := GetSession(other.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("the rotated session survived DeleteOtherUserSessions: err = %v", err)
+	}
+}
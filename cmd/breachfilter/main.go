@@ -0,0 +1,76 @@
+// Command breachfilter builds the bloom filter BREACHED_PASSWORD_FILTER points at from a list of
+// SHA-1 password hashes, one per line. Lines in the HASH:COUNT format of the Pwned Passwords
+// downloads are accepted as they are.
+//
+//	go run ./cmd/breachfilter -in pwned-passwords-sha1.txt -out breached.bloom -fp 0.001
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"lightRoom/utils"
+	"log"
+	"os"
+	"strings"
+)
+
+// eachDigest calls add with every valid hash in the list, returning how many lines were skipped.
+func eachDigest(path string, add func([sha1.Size]byte)) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		decoded, err := hex.DecodeString(hash)
+		if err != nil || len(decoded) != sha1.Size {
+			skipped++
+			continue
+		}
+		add([sha1.Size]byte(decoded))
+	}
+	return skipped, scanner.Err()
+}
+
+func main() {
+	in := flag.String("in", "", "file of SHA-1 password hashes, one per line")
+	out := flag.String("out", "breached.bloom", "where to write the filter")
+	falsePositive := flag.Float64("fp", 0.001, "false positive rate the filter is sized for")
+	flag.Parse()
+
+	if *in == "" || *falsePositive <= 0 || *falsePositive >= 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The list is read twice, once to size the filter and once to fill it, so it never has to fit in memory.
+	var count uint64
+	skipped, err := eachDigest(*in, func([sha1.Size]byte) { count++ })
+	if err != nil {
+		log.Fatalf("Unable to read %v: %v", *in, err)
+	}
+
+	filter := utils.NewBloomFilter(count, *falsePositive)
+	if _, err = eachDigest(*in, filter.Add); err != nil {
+		log.Fatalf("Unable to read %v: %v", *in, err)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Unable to create %v: %v", *out, err)
+	}
+	size, err := filter.WriteTo(file)
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		log.Fatalf("Unable to write %v: %v", *out, err)
+	}
+	log.Printf("Wrote %v hashes to %v (%d bytes), skipped %d unreadable lines", count, *out, size, skipped)
+}
@@ -13,6 +13,11 @@ import (
 	"lightRoom/db"
 	_ "lightRoom/docs" // docs is generated by Swag CLI, you have to import it.
 	"lightRoom/models"
+	"lightRoom/payments"
+	"lightRoom/privacy"
+	"lightRoom/renditions"
+	"lightRoom/sso"
+	"lightRoom/storage"
 	"lightRoom/utils"
 	"log"
 	"net/http"
@@ -25,10 +30,20 @@ func registerAPI(router *chi.Mux) {
 	router.Route("/api/v1/auth", func(router chi.Router) {
 		router.Post("/sign-up", api.CreateUser)
 		router.Post("/login", api.Login)
+		router.Post("/login/mfa", api.LoginMfa)
+		router.Post("/webauthn/login/begin", api.BeginWebAuthnLogin)
+		router.Post("/webauthn/login/finish", api.FinishWebAuthnLogin)
+		router.Get("/oauth/providers", api.GetOAuthProviders)
+		router.Get("/oauth/{provider}/start", api.StartOAuthLogin)
+		router.Get("/oauth/{provider}/callback", api.OAuthCallback)
+		router.Post("/magic-link", api.RequestMagicLink)
+		router.Post("/magic-link/verify", api.MagicLinkLogin)
 		router.Post("/forgot-password", api.ForgotPassword)
 		router.Post("/reset-password", api.PasswordReset)
 		router.Post("/refresh", api.Refresh)
 		router.Post("/account-verification", api.Verify)
+		router.Post("/resend-verification", api.ResendVerification)
+		router.Post("/change-email/confirm", api.ConfirmEmailChange)
 
 		// Protected Routes (within the same /api/v1/auth block)
 		router.Group(func(router chi.Router) {
@@ -40,21 +55,134 @@ func registerAPI(router *chi.Mux) {
 
 			router.Get("/me", api.Me)
 			router.Post("/logout", api.LogOut)
+			router.Post("/change-password", api.ChangePassword)
+			router.Post("/change-email", api.RequestEmailChange)
+			router.Get("/sessions", api.GetSessions)
+			router.Delete("/sessions", api.RevokeAllSessions)
+			router.Delete("/sessions/{id}", api.RevokeSession)
+			router.Get("/2fa", api.GetMfaStatus)
+			router.Post("/2fa/totp/enroll", api.EnrollTotp)
+			router.Post("/2fa/totp/confirm", api.ConfirmTotp)
+			router.Post("/2fa/totp/disable", api.DisableTotp)
+			router.Post("/2fa/recovery-codes", api.RegenerateRecoveryCodes)
+			router.Post("/webauthn/register/begin", api.BeginWebAuthnRegistration)
+			router.Post("/webauthn/register/finish", api.FinishWebAuthnRegistration)
+			router.Get("/webauthn/credentials", api.GetWebAuthnCredentials)
+			router.Delete("/webauthn/credentials/{id}", api.DeleteWebAuthnCredential)
+			router.Get("/api-keys", api.GetAPIKeys)
+			router.Post("/api-keys", api.CreateAPIKey)
+			router.Delete("/api-keys/{id}", api.DeleteAPIKey)
 		})
 
 	})
+	router.Route("/api/v1/users", func(router chi.Router) {
+		router.Use(utils.BearerTokenMiddleware)
+		// AUTH MIDDLEWARE
+		router.Use(jwtauth.Verifier(utils.TokenAuth))
+		// AUTHENTICATOR
+		router.Use(utils.LightRoomTicator)
+
+		router.Patch("/me", api.UpdateMe)
+		router.Post("/me/exports", api.RequestDataExport)
+		router.Get("/me/exports", api.GetDataExports)
+		router.Get("/me/exports/{id}", api.GetDataExport)
+		router.Post("/me/deletion", api.ScheduleAccountDeletion)
+		router.Delete("/me/deletion", api.CancelAccountDeletion)
+	})
 	router.Route("/api/v1/misc", func(router chi.Router) {
 
+		router.Group(func(router chi.Router) {
+			router.Use(utils.BearerTokenMiddleware)
+			// AUTHENTICATOR, a JWT or an X-API-Key
+			router.Use(utils.APIKeyTicator)
+			router.With(utils.RequireScope(string(models.ScopeUpload))).Post("/upload-file", api.UploadFile)
+			router.With(utils.RequireScope(string(models.ScopeRead))).Get("/files", api.GetMyFiles)
+			router.With(utils.RequireScope(string(models.ScopeRead))).Get("/files/{id}", api.GetFile)
+			router.With(utils.RequireScope(string(models.ScopeDeleteFiles))).Delete("/files/{id}", api.DeleteFile)
+		})
+
+	})
+	// Local and in-memory storage have no CDN in front of them, so the API serves their files.
+	if utils.Settings.StorageBackend != "r2" {
+		router.Get("/files/*", api.ServeFile)
+	}
+	router.Route("/api/v1/portfolios", func(router chi.Router) {
+		router.Get("/", api.GetPortfolios)
+
+		router.Group(func(router chi.Router) {
+			router.Use(utils.BearerTokenMiddleware)
+			// AUTHENTICATOR, a JWT or an X-API-Key
+			router.Use(utils.APIKeyTicator)
+			router.With(utils.RequireScope(string(models.ScopeRead))).Get("/me", api.GetMyPortfolios)
+			router.With(utils.RequireScope(string(models.ScopeRead))).Get("/{id}/download", api.DownloadPortfolio)
+
+			router.Group(func(router chi.Router) {
+				router.Use(utils.RequireScope(string(models.ScopeManagePortfolios)))
+				router.Post("/", api.CreatePortfolio)
+				router.Patch("/{id}", api.UpdatePortfolio)
+				router.Delete("/{id}", api.DeletePortfolio)
+				router.Post("/{id}/tags", api.AddPortfolioTags)
+				router.Delete("/{id}/tags/{tagID}", api.RemovePortfolioTag)
+			})
+		})
+
+		router.Get("/{id}", api.GetPortfolio)
+	})
+	router.Route("/api/v1/tags", func(router chi.Router) {
+		router.Get("/", api.GetTags)
+		router.Get("/{id}", api.GetTag)
+
 		router.Group(func(router chi.Router) {
 			router.Use(utils.BearerTokenMiddleware)
 			// AUTH MIDDLEWARE
 			router.Use(jwtauth.Verifier(utils.TokenAuth))
 			// AUTHENTICATOR
 			router.Use(utils.LightRoomTicator)
-			router.Post("/upload-file", api.UploadFile)
-			router.Post("/delete-file", api.DeleteFile)
+			router.Post("/", api.CreateTag)
 		})
+	})
+	router.Route("/api/v1/orders", func(router chi.Router) {
+		router.Use(utils.BearerTokenMiddleware)
+		// AUTH MIDDLEWARE
+		router.Use(jwtauth.Verifier(utils.TokenAuth))
+		// AUTHENTICATOR
+		router.Use(utils.LightRoomTicator)
 
+		router.Post("/checkout", api.Checkout)
+		router.Get("/", api.GetMyOrders)
+		router.Get("/{id}", api.GetOrder)
+	})
+	router.Group(func(router chi.Router) {
+		router.Use(utils.BearerTokenMiddleware)
+		// AUTH MIDDLEWARE
+		router.Use(jwtauth.Verifier(utils.TokenAuth))
+		// AUTHENTICATOR
+		router.Use(utils.LightRoomTicator)
+
+		router.Get("/api/v1/entitlements", api.GetMyEntitlements)
+	})
+	router.Route("/api/v1/payments", func(router chi.Router) {
+		// Providers authenticate webhooks with their own signatures, not bearer tokens.
+		router.Post("/webhook", api.PaymentWebhook)
+		if utils.Settings.PaymentProvider == "fake" {
+			router.Post("/fake/checkout/{reference}", api.FakeCheckout)
+		}
+	})
+	router.Route("/api/v1/admin", func(router chi.Router) {
+		router.Use(utils.BearerTokenMiddleware)
+		// AUTH MIDDLEWARE
+		router.Use(jwtauth.Verifier(utils.TokenAuth))
+		// AUTHENTICATOR
+		router.Use(utils.LightRoomTicator)
+		// ADMIN ONLY
+		router.Use(utils.RequireRole(string(models.RoleAdmin)))
+
+		router.Get("/users", api.AdminGetUsers)
+		router.Post("/users/{id}/suspend", api.AdminSuspendUser)
+		router.Post("/users/{id}/unsuspend", api.AdminUnsuspendUser)
+		router.Patch("/users/{id}/role", api.AdminSetUserRole)
+		router.Patch("/tags/{id}", api.AdminUpdateTag)
+		router.Delete("/tags/{id}", api.AdminDeleteTag)
 	})
 
 }
@@ -88,6 +216,9 @@ func rootHandler(writer http.ResponseWriter, request *http.Request) {
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
 func main() {
 	//LOADING ENVIRONMENTAL VARIABLES
 	err := godotenv.Load()
@@ -101,8 +232,18 @@ func main() {
 	models.Init()
 	//Redis InIt
 	cache.RedisInit(utils.Settings.RedisDsn)
+	//Storage Init
+	storage.Init()
+	renditions.Init()
+	//Payments Init
+	payments.Init()
 	//Auth Init
 	utils.AuthInit()
+	utils.PasswordPolicyInit()
+	sso.Init()
+	//Account deletion and data export sweeper
+	privacy.Init()
+	utils.ResolveAPIKey = api.ResolveAPIKey
 	// Initialize the validator instance
 	api.InitializeValidator()
 	//API ROUTER
@@ -114,8 +255,8 @@ func main() {
 	baseRouter.Use(cors.Handler(
 		cors.Options{
 			AllowedOrigins:   []string{"*"},
-			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-Key"},
 			ExposedHeaders:   []string{"Link"},
 			AllowCredentials: false,
 			MaxAge:           300,
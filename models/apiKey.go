@@ -0,0 +1,74 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"lightRoom/db"
+	"time"
+)
+
+type APIKeyScope string
+
+const (
+	ScopeRead             APIKeyScope = "read"
+	ScopeUpload           APIKeyScope = "upload"
+	ScopeManagePortfolios APIKeyScope = "manage-portfolios"
+	ScopeDeleteFiles      APIKeyScope = "delete-files"
+	// ScopeAdmin lets a key act with its owner's admin rights, keys without it act as plain users.
+	ScopeAdmin APIKeyScope = "admin"
+)
+
+// APIKey lets scripts act for a user without a session. Only a hash of the key is kept,
+// the prefix is stored in the clear so the key can be found and recognised in listings.
+type APIKey struct {
+	ID         uuid.UUID     `gorm:"primaryKey unique not null" json:"id"`
+	UserID     uuid.UUID     `gorm:"index;not null" json:"-"`
+	User       *User         `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Name       string        `json:"name"`
+	Prefix     string        `gorm:"uniqueIndex;not null" json:"prefix"`
+	KeyHash    string        `gorm:"not null" json:"-"`
+	Scopes     []APIKeyScope `gorm:"serializer:json;type:jsonb" json:"scopes"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+	LastUsedAt *time.Time    `json:"last_used_at"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+func CreateAPIKey(apiKey APIKey) error {
+	return db.Db.Create(&apiKey).Error
+}
+
+func GetUserAPIKeys(userID uuid.UUID) ([]APIKey, error) {
+	var apiKeys []APIKey
+
+	err := db.Db.Where("user_id = ?", userID).Order("created_at").Find(&apiKeys).Error
+
+	return apiKeys, err
+}
+
+func CountUserAPIKeys(userID uuid.UUID) int64 {
+	var count int64
+	db.Db.Model(&APIKey{}).Where("user_id = ?", userID).Count(&count)
+	return count
+}
+
+func GetAPIKeyByPrefix(prefix string) (APIKey, error) {
+	var apiKey APIKey
+
+	err := db.Db.Preload("User").Where("prefix = ?", prefix).First(&apiKey).Error
+
+	return apiKey, err
+}
+
+func UpdateAPIKeyLastUsed(id uuid.UUID) error {
+	return db.Db.Model(&APIKey{ID: id}).Update("last_used_at", time.Now()).Error
+}
+
+// DeleteUserAPIKey revokes one of the user's keys, reporting whether it existed.
+func DeleteUserAPIKey(userID, id uuid.UUID) (bool, error) {
+	result := db.Db.Where("user_id = ? AND id = ?", userID, id).Delete(&APIKey{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteUserAPIKeys revokes every key the user holds.
+func DeleteUserAPIKeys(userID uuid.UUID) error {
+	return db.Db.Where("user_id = ?", userID).Delete(&APIKey{}).Error
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"lightRoom/db"
+	"time"
+)
+
+// Asset records a file pushed to the storage backend and who owns it. Private assets
+// have their URL pointed at the watermarked preview, the original is only reachable by key.
+type Asset struct {
+	ID          uuid.UUID        `gorm:"primaryKey unique not null" json:"id"`
+	UserID      uuid.UUID        `gorm:"index;uniqueIndex:idx_asset_owner_file_hash;not null" json:"user_id"`
+	User        *User            `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	FileType    string           `gorm:"uniqueIndex:idx_asset_owner_file_hash" json:"file_type"`
+	FileName    string           `json:"file_name"`
+	StorageKey  string           `gorm:"uniqueIndex;not null" json:"-"`
+	URL         string           `json:"url"`
+	IsPrivate   bool             `gorm:"default:false;not null" json:"is_private"`
+	Size        int64            `json:"size"`
+	ContentType string           `json:"content_type"`
+	ContentHash string           `gorm:"uniqueIndex:idx_asset_owner_file_hash" json:"-"`
+	CreatedAt   time.Time        `json:"uploaded_at"`
+	Renditions  []AssetRendition `gorm:"constraint:OnDelete:CASCADE;" json:"renditions"`
+}
+
+// AssetRendition is a resized copy of an image asset, such as a thumbnail.
+type AssetRendition struct {
+	ID          uuid.UUID `gorm:"primaryKey unique not null" json:"id"`
+	AssetID     uuid.UUID `gorm:"uniqueIndex:idx_asset_rendition_name;not null" json:"asset_id"`
+	Name        string    `gorm:"uniqueIndex:idx_asset_rendition_name;not null" json:"name"`
+	StorageKey  string    `gorm:"not null" json:"-"`
+	URL         string    `json:"url"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func CreateAsset(asset Asset) error {
+	return db.Db.Create(&asset).Error
+}
+
+func GetAsset(assetID uuid.UUID) (Asset, error) {
+	var asset Asset
+
+	err := db.Db.Preload("Renditions").Where("id = ?", assetID).First(&asset).Error
+
+	return asset, err
+}
+
+// GetUserAssetByHash finds an asset the user already uploaded with the same content as the same
+// file type. The file type decides the key, visibility and renditions, so it is part of the match.
+func GetUserAssetByHash(userID uuid.UUID, fileType, contentHash string) (Asset, error) {
+	var asset Asset
+
+	err := db.Db.Preload("Renditions").Where("user_id = ? AND file_type = ? AND content_hash = ?", userID, fileType, contentHash).First(&asset).Error
+
+	return asset, err
+}
+
+func GetUserAssets(userID uuid.UUID, limit, offset int) ([]Asset, error) {
+	var assets []Asset
+
+	err := db.Db.Preload("Renditions").Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Offset(offset).Find(&assets).Error
+
+	return assets, err
+}
+
+// GetAssetsByURL loads the assets, with their renditions, that were served at urls.
+func GetAssetsByURL(urls []string) ([]Asset, error) {
+	var assets []Asset
+	if len(urls) == 0 {
+		return assets, nil
+	}
+
+	err := db.Db.Preload("Renditions").Where("url IN ?", urls).Find(&assets).Error
+
+	return assets, err
+}
+
+// GetUserPrivateAssetsByURL loads the private assets of userID whose preview is served at urls.
+func GetUserPrivateAssetsByURL(userID uuid.UUID, urls []string) ([]Asset, error) {
+	var assets []Asset
+	if len(urls) == 0 {
+		return assets, nil
+	}
+
+	err := db.Db.Where("user_id = ? AND is_private = ? AND url IN ?", userID, true, urls).Find(&assets).Error
+
+	return assets, err
+}
+
+func SetAssetURL(assetID uuid.UUID, url string) error {
+	return db.Db.Model(&Asset{}).Where("id = ?", assetID).Update("url", url).Error
+}
+
+func DeleteAsset(assetID uuid.UUID) error {
+	return db.Db.Select("Renditions").Delete(&Asset{ID: assetID}).Error
+}
+
+func CreateAssetRendition(rendition AssetRendition) error {
+	return db.Db.Create(&rendition).Error
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"lightRoom/db"
+	"lightRoom/utils"
+	"time"
+)
+
+// ExternalIdentity links an account at an identity provider to a user.
+type ExternalIdentity struct {
+	ID        uuid.UUID `gorm:"primaryKey unique not null" json:"id"`
+	UserID    uuid.UUID `gorm:"index;not null" json:"-"`
+	User      *User     `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Provider  string    `gorm:"uniqueIndex:idx_identity_provider_subject;not null" json:"provider"`
+	Subject   string    `gorm:"uniqueIndex:idx_identity_provider_subject;not null" json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func CreateExternalIdentity(identity ExternalIdentity) error {
+	identity.Email = utils.NormalizeEmail(identity.Email)
+	return db.Db.Create(&identity).Error
+}
+
+func GetExternalIdentity(provider, subject string) (ExternalIdentity, error) {
+	var identity ExternalIdentity
+
+	err := db.Db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+
+	return identity, err
+}
+
+func GetUserExternalIdentities(userID uuid.UUID) ([]ExternalIdentity, error) {
+	var identities []ExternalIdentity
+
+	err := db.Db.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+
+	return identities, err
+}
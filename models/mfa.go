@@ -0,0 +1,81 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"lightRoom/db"
+	"time"
+)
+
+// RecoveryCode is a single-use stand-in for a TOTP code, only its hash is kept.
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"primaryKey unique not null"`
+	UserID    uuid.UUID `gorm:"index:idx_recovery_code_user_hash;not null"`
+	User      *User     `gorm:"constraint:OnDelete:CASCADE;"`
+	CodeHash  string    `gorm:"index:idx_recovery_code_user_hash;not null"`
+	CreatedAt time.Time
+}
+
+func recoveryCodes(userID uuid.UUID, codeHashes []string) []RecoveryCode {
+	codes := make([]RecoveryCode, len(codeHashes))
+	for index, codeHash := range codeHashes {
+		codes[index] = RecoveryCode{ID: uuid.New(), UserID: userID, CodeHash: codeHash}
+	}
+	return codes
+}
+
+// EnableTotp turns two-factor on with the confirmed secret and replaces any recovery codes.
+func EnableTotp(userID uuid.UUID, secret string, step int64, codeHashes []string) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled": true, "totp_secret": secret, "totp_last_step": step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err = tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(recoveryCodes(userID, codeHashes)).Error
+	})
+}
+
+func DisableTotp(userID uuid.UUID) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled": false, "totp_secret": "", "totp_last_step": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// UseTotpStep records step as used, it returns false when that step or a later one
+// was accepted before, which is how a code replayed within its window is caught.
+func UseTotpStep(userID uuid.UUID, step int64) (bool, error) {
+	result := db.Db.Model(&User{}).Where("id = ? AND totp_last_step < ?", userID, step).Update("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UseRecoveryCode consumes the code, it returns false when the user has no such unused code.
+func UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	result := db.Db.Where("user_id = ? AND code_hash = ?", userID, codeHash).Delete(&RecoveryCode{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(recoveryCodes(userID, codeHashes)).Error
+	})
+}
+
+func CountRecoveryCodes(userID uuid.UUID) int64 {
+	var count int64
+	db.Db.Model(&RecoveryCode{}).Where("user_id = ?", userID).Count(&count)
+	return count
+}
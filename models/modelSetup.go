@@ -4,5 +4,5 @@ import "lightRoom/db"
 
 func Init() {
 	// Auto Migrate
-	db.Db.AutoMigrate(&User{}, &Tag{}, &Portfolio{})
+	db.Db.AutoMigrate(&User{}, &Tag{}, &Portfolio{}, &Asset{}, &AssetRendition{}, &Order{}, &OrderItem{}, &Entitlement{}, &PaymentEvent{}, &RecoveryCode{}, &WebAuthnCredential{}, &ExternalIdentity{}, &APIKey{}, &DataExport{})
 }
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"lightRoom/db"
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	OrderPending OrderStatus = "pending"
+	OrderPaid    OrderStatus = "paid"
+	OrderFailed  OrderStatus = "failed"
+)
+
+// Order is a checkout of one or more portfolios. Prices are in the currency's minor unit.
+type Order struct {
+	ID                uuid.UUID   `gorm:"primaryKey unique not null" json:"id"`
+	UserID            uuid.UUID   `gorm:"index;not null" json:"user_id"`
+	Status            OrderStatus `gorm:"type:varchar(16);index;not null" json:"status"`
+	Total             int         `json:"total"`
+	Currency          string      `json:"currency"`
+	Provider          string      `gorm:"uniqueIndex:idx_order_provider_reference" json:"provider"`
+	ProviderReference *string     `gorm:"uniqueIndex:idx_order_provider_reference" json:"-"`
+	Items             []OrderItem `gorm:"constraint:OnDelete:CASCADE;" json:"items"`
+	PaidAt            *time.Time  `json:"paid_at"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
+}
+
+type OrderItem struct {
+	ID          uuid.UUID `gorm:"primaryKey unique not null" json:"id"`
+	OrderID     uuid.UUID `gorm:"index;not null" json:"order_id"`
+	PortfolioID uuid.UUID `gorm:"index;not null" json:"portfolio_id"`
+	Title       string    `json:"name"`
+	Price       int       `json:"price"`
+}
+
+// Entitlement grants a user the clean originals of a portfolio they paid for.
+type Entitlement struct {
+	ID          uuid.UUID `gorm:"primaryKey unique not null" json:"id"`
+	UserID      uuid.UUID `gorm:"uniqueIndex:idx_entitlement_user_portfolio;not null" json:"user_id"`
+	PortfolioID uuid.UUID `gorm:"uniqueIndex:idx_entitlement_user_portfolio;not null" json:"portfolio_id"`
+	OrderID     uuid.UUID `gorm:"index;not null" json:"order_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PaymentEvent remembers provider webhook events already handled so redelivery is harmless.
+type PaymentEvent struct {
+	Provider    string    `gorm:"primaryKey"`
+	EventID     string    `gorm:"primaryKey"`
+	Type        string    `gorm:"not null"`
+	ProcessedAt time.Time `gorm:"autoCreateTime"`
+}
+
+var ErrOrderNotFound = errors.New("order not found")
+
+func CreateOrder(order Order) error {
+	return db.Db.Create(&order).Error
+}
+
+func GetOrder(orderID uuid.UUID) (Order, error) {
+	var order Order
+
+	err := db.Db.Preload("Items").Where("id = ?", orderID).First(&order).Error
+
+	return order, err
+}
+
+func GetUserOrders(userID uuid.UUID, limit, offset int) ([]Order, error) {
+	var orders []Order
+
+	err := db.Db.Preload("Items").Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Offset(offset).Find(&orders).Error
+
+	return orders, err
+}
+
+func SetOrderProviderReference(orderID uuid.UUID, provider, reference string) error {
+	return db.Db.Model(&Order{}).Where("id = ?", orderID).
+		Updates(map[string]interface{}{"provider": provider, "provider_reference": reference}).Error
+}
+
+// recordPaymentEvent returns false when the event was handled before.
+func recordPaymentEvent(tx *gorm.DB, provider, eventID, eventType string) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&PaymentEvent{Provider: provider, EventID: eventID, Type: eventType})
+	return result.RowsAffected > 0, result.Error
+}
+
+func lockOrderByReference(tx *gorm.DB, provider, reference string) (Order, error) {
+	var order Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("provider = ? AND provider_reference = ?", provider, reference).First(&order).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return order, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// FulfillOrder marks the order behind a provider reference paid and grants its entitlements.
+// Replaying the same event, or a second success event for a paid order, changes nothing.
+func FulfillOrder(provider, eventID, eventType, reference string) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		isNew, err := recordPaymentEvent(tx, provider, eventID, eventType)
+		if err != nil || !isNew {
+			return err
+		}
+
+		order, err := lockOrderByReference(tx, provider, reference)
+		if err != nil {
+			return err
+		}
+		if order.Status == OrderPaid {
+			return nil
+		}
+
+		paidAt := time.Now()
+		err = tx.Model(&order).Updates(map[string]interface{}{"status": OrderPaid, "paid_at": paidAt}).Error
+		if err != nil {
+			return err
+		}
+
+		var items []OrderItem
+		if err = tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+			return err
+		}
+		for _, item := range items {
+			entitlement := Entitlement{ID: uuid.New(), UserID: order.UserID, PortfolioID: item.PortfolioID, OrderID: order.ID}
+			if err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entitlement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FailOrder marks a pending order failed, paid orders are left alone.
+func FailOrder(provider, eventID, eventType, reference string) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		isNew, err := recordPaymentEvent(tx, provider, eventID, eventType)
+		if err != nil || !isNew {
+			return err
+		}
+
+		order, err := lockOrderByReference(tx, provider, reference)
+		if err != nil {
+			return err
+		}
+		if order.Status != OrderPending {
+			return nil
+		}
+		return tx.Model(&order).Update("status", OrderFailed).Error
+	})
+}
+
+func MarkOrderFailed(orderID uuid.UUID) error {
+	return db.Db.Model(&Order{}).Where("id = ? AND status = ?", orderID, OrderPending).Update("status", OrderFailed).Error
+}
+
+func HasEntitlement(userID, portfolioID uuid.UUID) bool {
+	var count int64
+	db.Db.Model(&Entitlement{}).Where("user_id = ? AND portfolio_id = ?", userID, portfolioID).Count(&count)
+	return count > 0
+}
+
+func GetUserEntitlements(userID uuid.UUID, limit, offset int) ([]Entitlement, error) {
+	var entitlements []Entitlement
+
+	err := db.Db.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Offset(offset).Find(&entitlements).Error
+
+	return entitlements, err
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"lightRoom/db"
 	"time"
 )
@@ -12,11 +13,19 @@ type Portfolio struct {
 	Description     string    `json:"description"`
 	Price           int       `json:"price"`
 	Tags            []Tag     `gorm:"many2many:portfolio_tags;" json:"tags"`
-	PaywalledImages []string  `gorm:"type:jsonb" json:"paywalled_images"`
-	Images          []string  `gorm:"type:jsonb" json:"images"`
+	PaywalledImages []string  `gorm:"serializer:json;type:jsonb" json:"paywalled_images"`
+	Images          []string  `gorm:"serializer:json;type:jsonb" json:"images"`
 	UserID          uuid.UUID `gorm:"foreignKey:User;constraint:OnDelete:CASCADE;" json:"user_id"` // Relationship with User
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
+	// ImagePreviews is filled by AttachImagePreviews and never stored.
+	ImagePreviews []ImagePreview `gorm:"-" json:"image_previews"`
+}
+
+// ImagePreview maps an image URL to the URLs of its renditions keyed by size name.
+type ImagePreview struct {
+	URL        string            `json:"url"`
+	Renditions map[string]string `json:"renditions"`
 }
 
 type Tag struct {
@@ -45,42 +54,229 @@ func GetTag(id uuid.UUID) (Tag, error) {
 	return fetchedTag, err
 
 }
+
+// GetTagsByID fetches every tag in tagIDs, unknown IDs are ignored.
+func GetTagsByID(tagIDs []uuid.UUID) ([]Tag, error) {
+	var fetchedTags []Tag
+	if len(tagIDs) == 0 {
+		return fetchedTags, nil
+	}
+	err := db.Db.Where("id IN ?", tagIDs).Find(&fetchedTags).Error
+	return fetchedTags, err
+}
+
+func UpdateTag(id uuid.UUID, title string) error {
+	result := db.Db.Model(&Tag{}).Where("id = ?", id).Update("title", title)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteTag detaches the tag from every portfolio before removing it.
+func DeleteTag(id uuid.UUID) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM portfolio_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&Tag{ID: id})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// GetTagByTitle looks a tag up by its exact title, ignoring case.
+func GetTagByTitle(title string) (Tag, error) {
+	var fetchedTag Tag
+
+	err := db.Db.Where("LOWER(title) = LOWER(?)", title).First(&fetchedTag).Error
+
+	return fetchedTag, err
+}
+
+// UpdateTagPortfolioCount recounts the portfolios attached to a tag through portfolio_tags.
 func UpdateTagPortfolioCount(id uuid.UUID) error {
-	var existingTag Tag
-	_ = db.Db.Where("id=?", id).First(&existingTag).Error
+	return refreshTagPortfolioCounts(db.Db, []uuid.UUID{id})
+}
+
+func refreshTagPortfolioCounts(tx *gorm.DB, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Model(&Tag{}).Where("id IN ?", ids).
+		Update("portfolio_count", gorm.Expr("(SELECT COUNT(*) FROM portfolio_tags WHERE portfolio_tags.tag_id = tags.id)")).Error
+}
 
-	existingTag.PortfolioCount++
-	return db.Db.Save(&existingTag).Error
+func tagIDs(tags []Tag) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, tag := range tags {
+		ids = append(ids, tag.ID)
+	}
+	return ids
+}
+
+// filterByTags narrows a portfolio query to those tagged with any, or when matchAll is set all, of tagID.
+func filterByTags(query *gorm.DB, matchAll bool, tagID []uuid.UUID) *gorm.DB {
+	if len(tagID) == 0 {
+		return query
+	}
+	tagged := db.Db.Table("portfolio_tags").Select("portfolio_id").Where("tag_id IN ?", tagID)
+	if matchAll {
+		tagged = tagged.Group("portfolio_id").Having("COUNT(DISTINCT tag_id) = ?", len(tagID))
+	}
+	return query.Where("id IN (?)", tagged)
 }
 
 func CreatePortfolio(portfolio Portfolio) error {
 
-	return db.Db.Create(&portfolio).Error
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&portfolio).Error; err != nil {
+			return err
+		}
+		return refreshTagPortfolioCounts(tx, tagIDs(portfolio.Tags))
+	})
 }
 
-func GetUserPortfolios(userID uuid.UUID, limit, offset int, tagID ...uuid.UUID) ([]Portfolio, error) {
-	var userPortfolios []Portfolio
+func GetPortfolio(portfolioID uuid.UUID) (Portfolio, error) {
+	var portfolio Portfolio
+
+	err := db.Db.Preload("Tags").Where("id = ?", portfolioID).First(&portfolio).Error
 
-	query := db.Db.Where("user_id=?", userID)
+	return portfolio, err
+}
 
-	if len(tagID) > 0 {
-		query.Where("tags IN (?)", tagID)
+func GetPortfoliosByID(portfolioIDs []uuid.UUID) ([]Portfolio, error) {
+	var portfolios []Portfolio
+	if len(portfolioIDs) == 0 {
+		return portfolios, nil
 	}
+	err := db.Db.Where("id IN ?", portfolioIDs).Find(&portfolios).Error
+	return portfolios, err
+}
+
+func GetUserPortfolios(userID uuid.UUID, limit, offset int, matchAll bool, tagID ...uuid.UUID) ([]Portfolio, error) {
+	var userPortfolios []Portfolio
 
-	err := query.Limit(limit).Offset(offset).Find(&userPortfolios).Error
+	query := db.Db.Preload("Tags").Where("user_id=?", userID)
+	query = filterByTags(query, matchAll, tagID)
+
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&userPortfolios).Error
 
 	return userPortfolios, err
 }
 
-func GetPortfolios(limit, offset int, tagID ...uuid.UUID) ([]Portfolio, error) {
+func GetPortfolios(limit, offset int, matchAll bool, tagID ...uuid.UUID) ([]Portfolio, error) {
 
 	var portfolios []Portfolio
-	query := db.Db
-	if len(tagID) > 0 {
-		query = db.Db.Where("tags in (?)", tagID)
-	}
-	err := query.Limit(limit).Offset(offset).Find(&portfolios).Error
+	query := db.Db.Preload("Tags")
+	query = filterByTags(query, matchAll, tagID)
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&portfolios).Error
 	return portfolios, err
 }
 
-//func UpdateUserPortfolio(userID, portfolioID uuid.UUID, portfolio Portfolio) error {}
+// UpdateUserPortfolio overwrites the editable fields of a portfolio owned by userID.
+// Zero values are written too, so callers should start from the stored portfolio.
+// UpdateUserPortfolio saves the editable fields of the user's portfolio and, when tags is not nil,
+// replaces its tags and recounts every tag involved, all in one transaction.
+func UpdateUserPortfolio(userID uuid.UUID, portfolio *Portfolio, tags *[]Tag) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Portfolio{}).
+			Where("id = ? AND user_id = ?", portfolio.ID, userID).
+			Select("title", "description", "price", "paywalled_images", "images", "updated_at").
+			Updates(portfolio)
+
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if tags == nil {
+			return nil
+		}
+
+		affectedTags := append(tagIDs(portfolio.Tags), tagIDs(*tags)...)
+		if err := tx.Model(portfolio).Association("Tags").Replace(*tags); err != nil {
+			return err
+		}
+		return refreshTagPortfolioCounts(tx, affectedTags)
+	})
+}
+
+// AddPortfolioTags attaches tags to a portfolio, leaving existing tags in place.
+func AddPortfolioTags(portfolio *Portfolio, tags []Tag) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(portfolio).Association("Tags").Append(tags); err != nil {
+			return err
+		}
+		return refreshTagPortfolioCounts(tx, tagIDs(tags))
+	})
+}
+
+// RemovePortfolioTags detaches tags from a portfolio.
+func RemovePortfolioTags(portfolio *Portfolio, tags []Tag) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(portfolio).Association("Tags").Delete(tags); err != nil {
+			return err
+		}
+		return refreshTagPortfolioCounts(tx, tagIDs(tags))
+	})
+}
+
+func DeleteUserPortfolio(userID, portfolioID uuid.UUID) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		var portfolio Portfolio
+		err := tx.Preload("Tags").Where("id = ? AND user_id = ?", portfolioID, userID).First(&portfolio).Error
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Select("Tags").Delete(&portfolio).Error; err != nil {
+			return err
+		}
+		return refreshTagPortfolioCounts(tx, tagIDs(portfolio.Tags))
+	})
+}
+
+// AttachImagePreviews fills ImagePreviews so listings can serve renditions instead of originals.
+// Images that were not uploaded through the API, or have no renditions, are left out.
+func AttachImagePreviews(portfolios []Portfolio) error {
+	var urls []string
+	for _, portfolio := range portfolios {
+		urls = append(urls, portfolio.Images...)
+	}
+
+	assets, err := GetAssetsByURL(urls)
+	if err != nil {
+		return err
+	}
+	renditionsByURL := map[string]map[string]string{}
+	for _, asset := range assets {
+		if len(asset.Renditions) == 0 {
+			continue
+		}
+		renditionURLs := map[string]string{}
+		for _, rendition := range asset.Renditions {
+			renditionURLs[rendition.Name] = rendition.URL
+		}
+		renditionsByURL[asset.URL] = renditionURLs
+	}
+
+	for index := range portfolios {
+		portfolios[index].ImagePreviews = []ImagePreview{}
+		for _, imageURL := range portfolios[index].Images {
+			if renditionURLs, ok := renditionsByURL[imageURL]; ok {
+				portfolios[index].ImagePreviews = append(portfolios[index].ImagePreviews, ImagePreview{URL: imageURL, Renditions: renditionURLs})
+			}
+		}
+	}
+	return nil
+}
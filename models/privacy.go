@@ -0,0 +1,133 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"lightRoom/db"
+	"time"
+)
+
+type DataExportStatus string
+
+const (
+	DataExportPending DataExportStatus = "pending"
+	DataExportReady   DataExportStatus = "ready"
+	DataExportFailed  DataExportStatus = "failed"
+)
+
+// DataExport is a ZIP of everything stored about a user, built in the background.
+type DataExport struct {
+	ID          uuid.UUID        `gorm:"primaryKey unique not null" json:"id"`
+	UserID      uuid.UUID        `gorm:"index;not null" json:"-"`
+	User        *User            `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Status      DataExportStatus `gorm:"type:varchar(16);index;not null" json:"status"`
+	StorageKey  string           `json:"-"`
+	Size        int64            `json:"size"`
+	CreatedAt   time.Time        `json:"created_at"`
+	CompletedAt *time.Time       `json:"completed_at"`
+	// ExpiresAt is when the archive is deleted again.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func CreateDataExport(export DataExport) error {
+	return db.Db.Create(&export).Error
+}
+
+func GetUserDataExport(userID, id uuid.UUID) (DataExport, error) {
+	var export DataExport
+
+	err := db.Db.Where("user_id = ? AND id = ?", userID, id).First(&export).Error
+
+	return export, err
+}
+
+func GetUserDataExports(userID uuid.UUID) ([]DataExport, error) {
+	var exports []DataExport
+
+	err := db.Db.Where("user_id = ?", userID).Order("created_at DESC").Find(&exports).Error
+
+	return exports, err
+}
+
+// HasPendingDataExport reports whether an export of the user is still being built.
+func HasPendingDataExport(userID uuid.UUID) bool {
+	var count int64
+	db.Db.Model(&DataExport{}).Where("user_id = ? AND status = ?", userID, DataExportPending).Count(&count)
+	return count > 0
+}
+
+func CompleteDataExport(id uuid.UUID, storageKey string, size int64) error {
+	completedAt := time.Now()
+	return db.Db.Model(&DataExport{ID: id}).Select("status", "storage_key", "size", "completed_at").
+		Updates(DataExport{Status: DataExportReady, StorageKey: storageKey, Size: size, CompletedAt: &completedAt}).Error
+}
+
+func FailDataExport(id uuid.UUID) error {
+	completedAt := time.Now()
+	return db.Db.Model(&DataExport{ID: id}).Select("status", "completed_at").
+		Updates(DataExport{Status: DataExportFailed, CompletedAt: &completedAt}).Error
+}
+
+// FailStaleDataExports gives up on exports still pending since before, their builder died with the process.
+func FailStaleDataExports(before time.Time) error {
+	return db.Db.Model(&DataExport{}).Where("status = ? AND created_at < ?", DataExportPending, before).
+		Updates(map[string]interface{}{"status": DataExportFailed, "completed_at": time.Now()}).Error
+}
+
+func GetExpiredDataExports(now time.Time, limit int) ([]DataExport, error) {
+	var exports []DataExport
+
+	err := db.Db.Where("expires_at <= ?", now).Limit(limit).Find(&exports).Error
+
+	return exports, err
+}
+
+func DeleteDataExport(id uuid.UUID) error {
+	return db.Db.Delete(&DataExport{ID: id}).Error
+}
+
+// PurgeUser deletes the user and everything they own in one transaction. Orders are kept for the
+// books with their owner set to uuid.Nil. Stored files must be removed by the caller first.
+func PurgeUser(userID uuid.UUID) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		var portfolios []Portfolio
+		if err := tx.Preload("Tags").Where("user_id = ?", userID).Find(&portfolios).Error; err != nil {
+			return err
+		}
+		var affectedTags []uuid.UUID
+		for index := range portfolios {
+			affectedTags = append(affectedTags, tagIDs(portfolios[index].Tags)...)
+			if err := tx.Select("Tags").Delete(&portfolios[index]).Error; err != nil {
+				return err
+			}
+		}
+		if err := refreshTagPortfolioCounts(tx, affectedTags); err != nil {
+			return err
+		}
+
+		var assetIDs []uuid.UUID
+		if err := tx.Model(&Asset{}).Where("user_id = ?", userID).Pluck("id", &assetIDs).Error; err != nil {
+			return err
+		}
+		if len(assetIDs) > 0 {
+			if err := tx.Where("asset_id IN ?", assetIDs).Delete(&AssetRendition{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", assetIDs).Delete(&Asset{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&Entitlement{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Order{}).Where("user_id = ?", userID).Update("user_id", uuid.Nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&DataExport{}).Error; err != nil {
+			return err
+		}
+		// Credentials, recovery codes, identities and api keys go with the user through their foreign keys.
+		return tx.Delete(&User{ID: userID}).Error
+	})
+}
@@ -2,32 +2,50 @@ package models
 
 import (
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"lightRoom/db"
+	"lightRoom/utils"
+	"time"
 )
 
 type Role string
 
 const (
-	user  Role = "User"
-	admin Role = "Admin"
+	RoleUser  Role = "User"
+	RoleAdmin Role = "Admin"
 )
 
+// ValidRole reports whether role is one of the roles a user can hold.
+func ValidRole(role Role) bool {
+	return role == RoleUser || role == RoleAdmin
+}
+
 type User struct {
-	ID         uuid.UUID `gorm:"primaryKey unique not null" json:"user_id"`
-	Name       string    `json:"name"`
-	Email      string    `gorm:"unique not null" json:"email"`
-	Password   string    `gorm:"unique not null" json:"password"`
-	IsVerified bool      `json:"is_verified"`
+	ID          uuid.UUID `gorm:"primaryKey unique not null" json:"user_id"`
+	Name        string    `json:"name"`
+	Email       string    `gorm:"unique not null" json:"email"`
+	Password    string    `gorm:"unique not null" json:"-"`
+	IsVerified  bool      `json:"is_verified"`
+	Role        Role      `gorm:"type:varchar(16);default:User;not null" json:"role"`
+	IsSuspended bool      `gorm:"default:false;not null" json:"is_suspended"`
+	TotpEnabled bool      `gorm:"default:false;not null" json:"totp_enabled"`
+	TotpSecret  string    `json:"-"`
+	// TotpLastStep is the time step of the last accepted code, codes cannot be replayed.
+	TotpLastStep int64 `gorm:"default:0;not null" json:"-"`
+	// DeletionScheduledAt is when the account and its data are purged, nil unless deletion was asked for.
+	DeletionScheduledAt *time.Time `gorm:"index" json:"deletion_scheduled_at"`
 }
 
 func CreateUser(user User) error {
-
+	user.Email = utils.NormalizeEmail(user.Email)
 	return db.Db.Create(&user).Error
 }
+
+// FetchViaMail finds the user by email ignoring case, rows stored before emails were normalised included.
 func FetchViaMail(email string) (User, error) {
 
 	var user User
-	err := db.Db.Where("email = ?", email).First(&user).Error
+	err := db.Db.Where("LOWER(email) = ?", utils.NormalizeEmail(email)).First(&user).Error
 	if err != nil {
 		return User{}, err
 	}
@@ -44,10 +62,14 @@ func GetUser(user_id uuid.UUID) (User, error) {
 func UpdateUser(user_id uuid.UUID, updateUser User) error {
 
 	var existingUser User
-	_ = db.Db.Where("id = ?", updateUser.ID).First(&existingUser).Error
+	err := db.Db.Where("id = ?", user_id).First(&existingUser).Error
+	if err != nil {
+		return err
+	}
 
+	updateUser.Email = utils.NormalizeEmail(updateUser.Email)
 	//	Updating the fields of the existing User with the new value
-	err := db.Db.Model(&existingUser).Updates(updateUser).Error
+	err = db.Db.Model(&existingUser).Updates(updateUser).Error
 
 	if err != nil {
 		return err
@@ -68,3 +90,54 @@ func GetUsers(limit int, offset int) ([]User, error) {
 
 	return users, err
 }
+
+// SetUserSuspended flips the suspension flag, false is written explicitly unlike UpdateUser.
+func SetUserSuspended(user_id uuid.UUID, suspended bool) error {
+	result := db.Db.Model(&User{}).Where("id = ?", user_id).Update("is_suspended", suspended)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func VerifyUser(user_id uuid.UUID) error {
+	return db.Db.Model(&User{}).Where("id = ?", user_id).Update("is_verified", true).Error
+}
+
+// ClaimUnverifiedUser verifies an account whose email was proven elsewhere and replaces its password.
+func ClaimUnverifiedUser(user_id uuid.UUID, password string) error {
+	return db.Db.Model(&User{}).Where("id = ?", user_id).
+		Updates(map[string]interface{}{"is_verified": true, "password": password}).Error
+}
+
+func SetUserRole(user_id uuid.UUID, role Role) error {
+	result := db.Db.Model(&User{}).Where("id = ?", user_id).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ScheduleUserDeletion marks the account for purging at deleteAt, CancelUserDeletion undoes it.
+func ScheduleUserDeletion(user_id uuid.UUID, deleteAt time.Time) error {
+	return db.Db.Model(&User{}).Where("id = ?", user_id).Update("deletion_scheduled_at", deleteAt).Error
+}
+
+func CancelUserDeletion(user_id uuid.UUID) error {
+	return db.Db.Model(&User{}).Where("id = ?", user_id).Update("deletion_scheduled_at", nil).Error
+}
+
+// GetUsersDueForDeletion returns accounts whose grace period is over.
+func GetUsersDueForDeletion(now time.Time, limit int) ([]User, error) {
+	var users []User
+
+	err := db.Db.Where("deletion_scheduled_at <= ?", now).Order("deletion_scheduled_at").Limit(limit).Find(&users).Error
+
+	return users, err
+}
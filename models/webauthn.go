@@ -0,0 +1,54 @@
+package models
+
+import (
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"lightRoom/db"
+	"time"
+)
+
+// WebAuthnCredential is a passkey or security key registered to a user.
+type WebAuthnCredential struct {
+	ID           uuid.UUID `gorm:"primaryKey unique not null" json:"id"`
+	UserID       uuid.UUID `gorm:"index;not null" json:"-"`
+	User         *User     `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Name         string    `json:"name"`
+	CredentialID []byte    `gorm:"uniqueIndex;not null" json:"-"`
+	// Credential is the record the webauthn library verifies assertions against.
+	Credential webauthn.Credential `gorm:"serializer:json;type:jsonb" json:"-"`
+	LastUsedAt *time.Time          `json:"last_used_at"`
+	CreatedAt  time.Time           `json:"created_at"`
+}
+
+func CreateWebAuthnCredential(credential WebAuthnCredential) error {
+	return db.Db.Create(&credential).Error
+}
+
+func GetUserWebAuthnCredentials(userID uuid.UUID) ([]WebAuthnCredential, error) {
+	var credentials []WebAuthnCredential
+
+	err := db.Db.Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error
+
+	return credentials, err
+}
+
+func GetWebAuthnCredentialByCredentialID(credentialID []byte) (WebAuthnCredential, error) {
+	var credential WebAuthnCredential
+
+	err := db.Db.Where("credential_id = ?", credentialID).First(&credential).Error
+
+	return credential, err
+}
+
+// UpdateWebAuthnCredentialUse stores the signature counter and flags of the latest assertion.
+func UpdateWebAuthnCredentialUse(id uuid.UUID, credential webauthn.Credential) error {
+	usedAt := time.Now()
+	return db.Db.Model(&WebAuthnCredential{ID: id}).Select("credential", "last_used_at").
+		Updates(WebAuthnCredential{Credential: credential, LastUsedAt: &usedAt}).Error
+}
+
+// DeleteUserWebAuthnCredential removes one of the user's credentials, reporting whether it existed.
+func DeleteUserWebAuthnCredential(userID, id uuid.UUID) (bool, error) {
+	result := db.Db.Where("user_id = ? AND id = ?", userID, id).Delete(&WebAuthnCredential{})
+	return result.RowsAffected > 0, result.Error
+}
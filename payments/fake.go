@@ -0,0 +1,71 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"io"
+	"lightRoom/models"
+	"net/http"
+	"strings"
+)
+
+// FakeProvider settles payments locally so checkout can be exercised without a payment
+// account. Its webhooks are not signed, never select it outside development.
+type FakeProvider struct {
+	appBaseUrl string
+}
+
+func NewFakeProvider(appBaseUrl string) *FakeProvider {
+	return &FakeProvider{appBaseUrl: strings.TrimSuffix(appBaseUrl, "/")}
+}
+
+func (fake *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateCheckout points the buyer at the API's fake checkout endpoint, which settles the order at once.
+func (fake *FakeProvider) CreateCheckout(ctx context.Context, order models.Order, customerEmail string) (CheckoutSession, error) {
+	reference := "fake_cs_" + uuid.NewString()
+	return CheckoutSession{
+		Reference: reference,
+		URL:       fake.appBaseUrl + "/api/v1/payments/fake/checkout/" + reference,
+	}, nil
+}
+
+// Complete builds the event the provider would have sent once the buyer paid, or failed to.
+func (fake *FakeProvider) Complete(reference string, succeeded bool) Event {
+	event := Event{ID: "fake_evt_" + uuid.NewString(), Type: PaymentSucceeded, RawType: "fake.payment_succeeded", Reference: reference}
+	if !succeeded {
+		event.Type = PaymentFailed
+		event.RawType = "fake.payment_failed"
+	}
+	return event
+}
+
+type fakeEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Reference string `json:"reference"`
+}
+
+// ParseWebhook accepts {"id", "type", "reference"} bodies so webhooks, duplicates
+// included, can be replayed against the real endpoint.
+func (fake *FakeProvider) ParseWebhook(request *http.Request) (Event, error) {
+	var rawEvent fakeEvent
+	if err := json.NewDecoder(io.LimitReader(request.Body, maxWebhookBody)).Decode(&rawEvent); err != nil {
+		return Event{}, err
+	}
+	if rawEvent.ID == "" || rawEvent.Reference == "" {
+		return Event{}, ErrInvalidEvent
+	}
+
+	event := Event{ID: rawEvent.ID, RawType: rawEvent.Type, Reference: rawEvent.Reference, Type: EventIgnored}
+	switch rawEvent.Type {
+	case "fake.payment_succeeded":
+		event.Type = PaymentSucceeded
+	case "fake.payment_failed":
+		event.Type = PaymentFailed
+	}
+	return event, nil
+}
@@ -0,0 +1,79 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"lightRoom/models"
+	"lightRoom/utils"
+	"log"
+	"net/http"
+)
+
+type EventType string
+
+const (
+	PaymentSucceeded EventType = "payment_succeeded"
+	PaymentFailed    EventType = "payment_failed"
+	// EventIgnored covers every provider event we do not act on.
+	EventIgnored EventType = "ignored"
+)
+
+var (
+	ErrInvalidSignature = errors.New("payments: webhook signature is invalid")
+	ErrInvalidEvent     = errors.New("payments: webhook event is malformed")
+)
+
+// CheckoutSession is where the buyer is sent to pay for an order.
+type CheckoutSession struct {
+	Reference string
+	URL       string
+}
+
+// Event is a verified provider webhook reduced to what fulfilment needs.
+type Event struct {
+	ID   string
+	Type EventType
+	// RawType is the provider's own name for the event.
+	RawType string
+	// Reference is the CheckoutSession.Reference the event is about.
+	Reference string
+}
+
+// PaymentProvider is implemented by every payment backend checkout can go through.
+type PaymentProvider interface {
+	Name() string
+	CreateCheckout(ctx context.Context, order models.Order, customerEmail string) (CheckoutSession, error)
+	// ParseWebhook verifies the request came from the provider and decodes it.
+	ParseWebhook(request *http.Request) (Event, error)
+}
+
+// Provider is the backend selected through utils.Settings.PaymentProvider.
+var Provider PaymentProvider
+
+func Init() {
+	switch utils.Settings.PaymentProvider {
+	case "stripe":
+		Provider = NewStripeProvider(StripeConfig{
+			SecretKey:     utils.Settings.StripeSecretKey,
+			WebhookSecret: utils.Settings.StripeWebhookSecret,
+			SuccessUrl:    utils.Settings.CheckoutSuccessUrl,
+			CancelUrl:     utils.Settings.CheckoutCancelUrl,
+		})
+	case "fake":
+		Provider = NewFakeProvider(utils.Settings.AppBaseUrl)
+	default:
+		log.Fatalf("unknown payment provider %q", utils.Settings.PaymentProvider)
+	}
+}
+
+// Process applies a verified event to the order it references. Events are recorded by
+// ID, so providers redelivering the same webhook never grant entitlements twice.
+func Process(event Event) error {
+	switch event.Type {
+	case PaymentSucceeded:
+		return models.FulfillOrder(Provider.Name(), event.ID, event.RawType, event.Reference)
+	case PaymentFailed:
+		return models.FailOrder(Provider.Name(), event.ID, event.RawType, event.Reference)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package payments
+
+import (
+	"errors"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"lightRoom/db"
+	"lightRoom/models"
+	"path/filepath"
+	"testing"
+)
+
+// setupPaymentsTest selects the fake provider and records a pending order for two portfolios.
+func setupPaymentsTest(t *testing.T) (*FakeProvider, models.Order, string) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "lightroom.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = database.AutoMigrate(&models.Order{}, &models.OrderItem{}, &models.Entitlement{}, &models.PaymentEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Db = database
+
+	fake := NewFakeProvider("http://api.test")
+	Provider = fake
+
+	order := models.Order{ID: uuid.New(), UserID: uuid.New(), Status: models.OrderPending, Total: 3000, Currency: "usd"}
+	for index := 0; index < 2; index++ {
+		order.Items = append(order.Items, models.OrderItem{ID: uuid.New(), PortfolioID: uuid.New(), Price: 1500})
+	}
+	if err = models.CreateOrder(order); err != nil {
+		t.Fatal(err)
+	}
+	reference := "fake_cs_" + uuid.NewString()
+	if err = models.SetOrderProviderReference(order.ID, fake.Name(), reference); err != nil {
+		t.Fatal(err)
+	}
+	return fake, order, reference
+}
+
+func entitlementCount(t *testing.T, userID uuid.UUID) int {
+	entitlements, err := models.GetUserEntitlements(userID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entitlements)
+}
+
+func TestProcessGrantsEntitlementsOnce(t *testing.T) {
+	fake, order, reference := setupPaymentsTest(t)
+
+	succeeded := fake.Complete(reference, true)
+	for delivery := 0; delivery < 2; delivery++ {
+		if err := Process(succeeded); err != nil {
+			t.Fatalf("delivery %d: %v", delivery, err)
+		}
+	}
+	// A second success event for a paid order changes nothing either.
+	if err := Process(fake.Complete(reference, true)); err != nil {
+		t.Fatal(err)
+	}
+
+	paid, _ := models.GetOrder(order.ID)
+	if paid.Status != models.OrderPaid || paid.PaidAt == nil {
+		t.Errorf("order is %s, paid at %v, want paid", paid.Status, paid.PaidAt)
+	}
+	if count := entitlementCount(t, order.UserID); count != 2 {
+		t.Errorf("%d entitlements granted, want one per portfolio", count)
+	}
+	for _, item := range order.Items {
+		if !models.HasEntitlement(order.UserID, item.PortfolioID) {
+			t.Errorf("no entitlement for portfolio %v", item.PortfolioID)
+		}
+	}
+}
+
+func TestProcessFailureLeavesPaidOrders(t *testing.T) {
+	fake, order, reference := setupPaymentsTest(t)
+
+	if err := Process(fake.Complete(reference, true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Process(fake.Complete(reference, false)); err != nil {
+		t.Fatal(err)
+	}
+	if paid, _ := models.GetOrder(order.ID); paid.Status != models.OrderPaid {
+		t.Errorf("a failure event after payment moved the order to %s", paid.Status)
+	}
+}
+
+func TestProcessFailedPayment(t *testing.T) {
+	fake, order, reference := setupPaymentsTest(t)
+
+	if err := Process(fake.Complete(reference, false)); err != nil {
+		t.Fatal(err)
+	}
+	if failed, _ := models.GetOrder(order.ID); failed.Status != models.OrderFailed {
+		t.Errorf("order is %s after a failed payment, want failed", failed.Status)
+	}
+	if count := entitlementCount(t, order.UserID); count != 0 {
+		t.Errorf("%d entitlements granted for a failed payment", count)
+	}
+}
+
+func TestProcessUnknownReference(t *testing.T) {
+	fake, _, _ := setupPaymentsTest(t)
+
+	if err := Process(fake.Complete("fake_cs_unknown", true)); !errors.Is(err, models.ErrOrderNotFound) {
+		t.Errorf("err = %v, want ErrOrderNotFound", err)
+	}
+}
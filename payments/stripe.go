@@ -0,0 +1,183 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lightRoom/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	stripeApiBase = "https://api.stripe.com/v1"
+	// stripeSignatureTolerance bounds how old a signed webhook may be, as Stripe's own libraries do.
+	stripeSignatureTolerance = 5 * time.Minute
+	maxWebhookBody           = 1 << 20
+)
+
+type StripeConfig struct {
+	SecretKey     string
+	WebhookSecret string
+	SuccessUrl    string
+	CancelUrl     string
+}
+
+// StripeProvider creates Stripe Checkout sessions and verifies Stripe webhooks over plain HTTP.
+type StripeProvider struct {
+	config     StripeConfig
+	apiBase    string
+	httpClient *http.Client
+}
+
+func NewStripeProvider(config StripeConfig) *StripeProvider {
+	return &StripeProvider{
+		config:     config,
+		apiBase:    stripeApiBase,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (stripe *StripeProvider) Name() string {
+	return "stripe"
+}
+
+type stripeSession struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+type stripeError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (stripe *StripeProvider) CreateCheckout(ctx context.Context, order models.Order, customerEmail string) (CheckoutSession, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", stripe.config.SuccessUrl)
+	form.Set("cancel_url", stripe.config.CancelUrl)
+	form.Set("client_reference_id", order.ID.String())
+	form.Set("customer_email", customerEmail)
+	form.Set("metadata[order_id]", order.ID.String())
+	for index, item := range order.Items {
+		prefix := fmt.Sprintf("line_items[%d]", index)
+		form.Set(prefix+"[quantity]", "1")
+		form.Set(prefix+"[price_data][currency]", order.Currency)
+		form.Set(prefix+"[price_data][unit_amount]", strconv.Itoa(item.Price))
+		form.Set(prefix+"[price_data][product_data][name]", item.Title)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, stripe.apiBase+"/checkout/sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return CheckoutSession{}, err
+	}
+	request.SetBasicAuth(stripe.config.SecretKey, "")
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Retrying a checkout for the same order must not open a second session.
+	request.Header.Set("Idempotency-Key", "checkout-"+order.ID.String())
+
+	response, err := stripe.httpClient.Do(request)
+	if err != nil {
+		return CheckoutSession{}, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return CheckoutSession{}, err
+	}
+	if response.StatusCode != http.StatusOK {
+		var apiError stripeError
+		_ = json.Unmarshal(body, &apiError)
+		return CheckoutSession{}, fmt.Errorf("stripe: checkout failed with %d: %s", response.StatusCode, apiError.Error.Message)
+	}
+
+	var session stripeSession
+	if err = json.Unmarshal(body, &session); err != nil {
+		return CheckoutSession{}, err
+	}
+	return CheckoutSession{Reference: session.ID, URL: session.URL}, nil
+}
+
+// verifySignature checks a Stripe-Signature header of the form t=timestamp,v1=signature.
+func (stripe *StripeProvider) verifySignature(header string, payload []byte) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if time.Since(time.Unix(signedAt, 0)).Abs() > stripeSignatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(stripe.config.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object struct {
+			ID            string `json:"id"`
+			PaymentStatus string `json:"payment_status"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+func (stripe *StripeProvider) ParseWebhook(request *http.Request) (Event, error) {
+	payload, err := io.ReadAll(io.LimitReader(request.Body, maxWebhookBody))
+	if err != nil {
+		return Event{}, err
+	}
+	if err = stripe.verifySignature(request.Header.Get("Stripe-Signature"), payload); err != nil {
+		return Event{}, err
+	}
+
+	var rawEvent stripeEvent
+	if err = json.Unmarshal(payload, &rawEvent); err != nil || rawEvent.ID == "" {
+		return Event{}, ErrInvalidEvent
+	}
+
+	event := Event{ID: rawEvent.ID, RawType: rawEvent.Type, Reference: rawEvent.Data.Object.ID, Type: EventIgnored}
+	switch rawEvent.Type {
+	case "checkout.session.completed":
+		// Delayed payment methods complete the session before the money arrives.
+		if rawEvent.Data.Object.PaymentStatus == "paid" {
+			event.Type = PaymentSucceeded
+		}
+	case "checkout.session.async_payment_succeeded":
+		event.Type = PaymentSucceeded
+	case "checkout.session.async_payment_failed", "checkout.session.expired":
+		event.Type = PaymentFailed
+	}
+	return event, nil
+}
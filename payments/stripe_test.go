@@ -0,0 +1,85 @@
+package payments
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func stripeSignatureHeader(secret string, signedAt time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func stripeWebhook(t *testing.T, payload, signature string) (Event, error) {
+	t.Helper()
+	stripe := NewStripeProvider(StripeConfig{WebhookSecret: testWebhookSecret})
+	request := httptest.NewRequest("POST", "/api/v1/payments/webhook", bytes.NewReader([]byte(payload)))
+	request.Header.Set("Stripe-Signature", signature)
+	return stripe.ParseWebhook(request)
+}
+
+func TestStripeWebhookSignature(t *testing.T) {
+	payload := `{"id": "evt_1", "type": "checkout.session.completed", "data": {"object": {"id": "cs_1", "payment_status": "paid"}}}`
+	now := time.Now()
+
+	event, err := stripeWebhook(t, payload, stripeSignatureHeader(testWebhookSecret, now, []byte(payload)))
+	if err != nil {
+		t.Fatalf("a correctly signed webhook was rejected: %v", err)
+	}
+	if event.ID != "evt_1" || event.Type != PaymentSucceeded || event.Reference != "cs_1" {
+		t.Errorf("ParseWebhook = %+v", event)
+	}
+
+	cases := map[string]string{
+		"no header":      "",
+		"wrong secret":   stripeSignatureHeader("whsec_other", now, []byte(payload)),
+		"other payload":  stripeSignatureHeader(testWebhookSecret, now, []byte(`{"id": "evt_2"}`)),
+		"too old":        stripeSignatureHeader(testWebhookSecret, now.Add(-10*time.Minute), []byte(payload)),
+		"no signature":   "t=" + strconv.FormatInt(now.Unix(), 10),
+		"bad timestamp":  "t=soon,v1=00",
+		"only v0 scheme": "t=" + strconv.FormatInt(now.Unix(), 10) + ",v0=00",
+	}
+	for name, signature := range cases {
+		if _, err := stripeWebhook(t, payload, signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: err = %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
+
+func TestStripeWebhookEventTypes(t *testing.T) {
+	cases := []struct {
+		eventType     string
+		paymentStatus string
+		want          EventType
+	}{
+		{"checkout.session.completed", "paid", PaymentSucceeded},
+		// Delayed payment methods complete the session before the money arrives.
+		{"checkout.session.completed", "unpaid", EventIgnored},
+		{"checkout.session.async_payment_succeeded", "paid", PaymentSucceeded},
+		{"checkout.session.async_payment_failed", "unpaid", PaymentFailed},
+		{"checkout.session.expired", "unpaid", PaymentFailed},
+		{"customer.created", "", EventIgnored},
+	}
+	for _, testCase := range cases {
+		payload := `{"id": "evt_1", "type": "` + testCase.eventType + `", "data": {"object": {"id": "cs_1", "payment_status": "` + testCase.paymentStatus + `"}}}`
+		event, err := stripeWebhook(t, payload, stripeSignatureHeader(testWebhookSecret, time.Now(), []byte(payload)))
+		if err != nil {
+			t.Fatalf("%s: %v", testCase.eventType, err)
+		}
+		if event.Type != testCase.want {
+			t.Errorf("%s with payment status %q = %s, want %s", testCase.eventType, testCase.paymentStatus, event.Type, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"lightRoom/cache"
+	"lightRoom/models"
+	"lightRoom/storage"
+	"lightRoom/utils"
+	"log"
+	"time"
+)
+
+// exportTimeout bounds how long building one archive may take.
+const exportTimeout = 10 * time.Minute
+
+// exportKey keeps archives below the private prefix, they are only handed out through signed links.
+func exportKey(userID, exportID uuid.UUID) string {
+	return fmt.Sprintf("%s/%s/EXPORTS/%s/%s.zip", utils.Settings.PrivateStoragePrefix, utils.Settings.Environment, userID, exportID)
+}
+
+// StartExport records a pending export of the user's data and builds it in the background.
+func StartExport(userID uuid.UUID) (models.DataExport, error) {
+	export := models.DataExport{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Status:    models.DataExportPending,
+		ExpiresAt: time.Now().Add(utils.Settings.DataExportRetention),
+	}
+	if err := models.CreateDataExport(export); err != nil {
+		return models.DataExport{}, err
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+
+		if err := build(ctx, export); err != nil {
+			log.Printf("Unable to build data export %v: %v", export.ID, err)
+			_ = models.FailDataExport(export.ID)
+		}
+	}()
+	return export, nil
+}
+
+// exportFiles gathers what is stored about the user, keyed by the file it is written to.
+func exportFiles(userID uuid.UUID) (map[string]interface{}, error) {
+	user, err := models.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := models.GetUserExternalIdentities(userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := models.GetUserWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := models.GetUserAPIKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+	portfolios, err := models.GetUserPortfolios(userID, -1, -1, false)
+	if err != nil {
+		return nil, err
+	}
+	assets, err := models.GetUserAssets(userID, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := models.GetUserOrders(userID, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	entitlements, err := models.GetUserEntitlements(userID, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := cache.GetUserSessions(userID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"profile.json": map[string]interface{}{
+			"user":                user,
+			"external_identities": identities,
+			"passkeys":            passkeys,
+			"api_keys":            apiKeys,
+		},
+		"portfolios.json":   portfolios,
+		"assets.json":       assets,
+		"orders.json":       orders,
+		"entitlements.json": entitlements,
+		"sessions.json":     sessions,
+	}, nil
+}
+
+func build(ctx context.Context, export models.DataExport) error {
+	files, err := exportFiles(export.UserID)
+	if err != nil {
+		return err
+	}
+
+	var archive bytes.Buffer
+	zipWriter := zip.NewWriter(&archive)
+	for name, content := range files {
+		fileWriter, err := zipWriter.Create(name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(fileWriter)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(content); err != nil {
+			return err
+		}
+	}
+	if err = zipWriter.Close(); err != nil {
+		return err
+	}
+
+	key := exportKey(export.UserID, export.ID)
+	size := int64(archive.Len())
+	if err = storage.Store.Put(ctx, key, &archive, size, "application/zip"); err != nil {
+		return err
+	}
+	return models.CompleteDataExport(export.ID, key, size)
+}
@@ -0,0 +1,110 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"lightRoom/cache"
+	"lightRoom/models"
+	"lightRoom/renditions"
+	"lightRoom/storage"
+	"log"
+	"time"
+)
+
+// sweepInterval is how often due deletions and expired exports are looked for.
+const sweepInterval = 10 * time.Minute
+
+// sweepBatch bounds the work one sweep does, anything left over is picked up by the next.
+const sweepBatch = 50
+
+// staleExportAfter is how long an export may stay pending before its builder is assumed dead.
+const staleExportAfter = time.Hour
+
+// Init starts the sweeper that purges accounts at the end of their grace period and deletes
+// expired exports.
+func Init() {
+	go func() {
+		for {
+			sweep(context.Background())
+			time.Sleep(sweepInterval)
+		}
+	}()
+}
+
+func sweep(ctx context.Context) {
+	now := time.Now()
+
+	users, err := models.GetUsersDueForDeletion(now, sweepBatch)
+	if err != nil {
+		log.Printf("Unable to look up accounts due for deletion %v", err)
+	}
+	for _, user := range users {
+		if err = PurgeAccount(ctx, user); err != nil {
+			log.Printf("Unable to delete account %v: %v", user.ID, err)
+		}
+	}
+
+	if err = models.FailStaleDataExports(now.Add(-staleExportAfter)); err != nil {
+		log.Printf("Unable to expire stale data exports %v", err)
+	}
+	exports, err := models.GetExpiredDataExports(now, sweepBatch)
+	if err != nil {
+		log.Printf("Unable to look up expired data exports %v", err)
+	}
+	for _, export := range exports {
+		if err = deleteExportFile(ctx, export); err == nil {
+			err = models.DeleteDataExport(export.ID)
+		}
+		if err != nil {
+			log.Printf("Unable to delete data export %v: %v", export.ID, err)
+		}
+	}
+}
+
+func deleteExportFile(ctx context.Context, export models.DataExport) error {
+	if export.StorageKey == "" {
+		return nil
+	}
+	err := storage.Store.Delete(ctx, export.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// PurgeAccount deletes the user's stored files, then the user with their portfolios and records.
+// Orders are kept, anonymised, because the books have to be. Files are removed first so a failure
+// leaves the user in place for the next sweep to retry rather than orphaning objects.
+func PurgeAccount(ctx context.Context, user models.User) error {
+	assets, err := models.GetUserAssets(user.ID, -1, -1)
+	if err != nil {
+		return err
+	}
+	for _, asset := range assets {
+		err = renditions.Delete(ctx, asset)
+		if err == nil {
+			err = storage.Store.Delete(ctx, asset.StorageKey)
+		}
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+
+	exports, err := models.GetUserDataExports(user.ID)
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if err = deleteExportFile(ctx, export); err != nil {
+			return err
+		}
+	}
+
+	if err = models.PurgeUser(user.ID); err != nil {
+		return err
+	}
+	_ = cache.DeleteUserSessions(user.ID.String())
+	_ = cache.UnsetUserSuspended(user.ID)
+	log.Printf("Deleted account %v", user.ID)
+	return nil
+}
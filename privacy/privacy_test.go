@@ -0,0 +1,141 @@
+package privacy
+
+import (
+	"bytes"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"lightRoom/cache"
+	"lightRoom/db"
+	"lightRoom/models"
+	"lightRoom/storage"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupPrivacyTest(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "lightroom.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Db = database
+	models.Init()
+
+	redisServer := miniredis.RunT(t)
+	cache.LRedis = redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	storage.Store = storage.NewMemoryStorage("http://files.test", "signing-secret")
+}
+
+func storeObject(t *testing.T, key string) {
+	if err := storage.Store.Put(context.Background(), key, bytes.NewReader([]byte("content")), 7, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createAccount makes a user owning a tagged portfolio, a stored file with a rendition,
+// a paid order with its entitlement, a data export and a signed-in session.
+func createAccount(t *testing.T, email string, deleteAt *time.Time, tag models.Tag) models.User {
+	user := models.User{ID: uuid.New(), Name: "Owner", Email: email, Password: "hash", IsVerified: true, Role: models.RoleUser, DeletionScheduledAt: deleteAt}
+	if err := models.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	portfolio := models.Portfolio{ID: uuid.New(), Title: "Portfolio", UserID: user.ID, Tags: []models.Tag{tag}}
+	if err := models.CreatePortfolio(portfolio); err != nil {
+		t.Fatal(err)
+	}
+
+	asset := models.Asset{ID: uuid.New(), UserID: user.ID, FileType: "PORTFOLIO", StorageKey: "test/PORTFOLIO/" + user.ID.String() + "/a.png", ContentHash: "a"}
+	storeObject(t, asset.StorageKey)
+	if err := models.CreateAsset(asset); err != nil {
+		t.Fatal(err)
+	}
+	rendition := models.AssetRendition{ID: uuid.New(), AssetID: asset.ID, Name: "thumb", StorageKey: "test/PORTFOLIO/" + user.ID.String() + "/a/thumb.png"}
+	storeObject(t, rendition.StorageKey)
+	if err := models.CreateAssetRendition(rendition); err != nil {
+		t.Fatal(err)
+	}
+
+	order := models.Order{ID: uuid.New(), UserID: user.ID, Status: models.OrderPaid, Total: 1000, Currency: "usd"}
+	if err := models.CreateOrder(order); err != nil {
+		t.Fatal(err)
+	}
+	db.Db.Create(&models.Entitlement{ID: uuid.New(), UserID: user.ID, PortfolioID: uuid.New(), OrderID: order.ID})
+
+	export := models.DataExport{ID: uuid.New(), UserID: user.ID, Status: models.DataExportReady, StorageKey: "exports/" + user.ID.String() + ".zip", ExpiresAt: time.Now().Add(time.Hour)}
+	storeObject(t, export.StorageKey)
+	if err := models.CreateDataExport(export); err != nil {
+		t.Fatal(err)
+	}
+
+	session := cache.Session{ID: uuid.NewString(), UserID: user.ID.String(), CreatedAt: time.Now(), LastSeenAt: time.Now()}
+	if err := cache.CreateSession(session, "token", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func countRows(t *testing.T, model interface{}, query string, args ...interface{}) int64 {
+	var count int64
+	if err := db.Db.Model(model).Where(query, args...).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestSweepPurgesAccountsAtTheEndOfTheGracePeriod(t *testing.T) {
+	setupPrivacyTest(t)
+	tag := models.Tag{ID: uuid.New(), Title: "landscape"}
+	if err := models.CreateTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	due := createAccount(t, "due@example.com", &past, tag)
+	waiting := createAccount(t, "waiting@example.com", &future, tag)
+
+	sweep(context.Background())
+
+	if _, err := models.GetUser(due.ID); err == nil {
+		t.Fatal("the account due for deletion was not purged")
+	}
+	for name, model := range map[string]interface{}{
+		"portfolios":   &models.Portfolio{},
+		"assets":       &models.Asset{},
+		"entitlements": &models.Entitlement{},
+		"data exports": &models.DataExport{},
+	} {
+		if count := countRows(t, model, "user_id = ?", due.ID); count != 0 {
+			t.Errorf("%d %s of the purged account are left", count, name)
+		}
+	}
+	if count := countRows(t, &models.Order{}, "user_id = ?", uuid.Nil); count != 1 {
+		t.Errorf("%d anonymised orders kept, want the purged account's order", count)
+	}
+	objects, _ := storage.Store.List(context.Background(), "")
+	for _, object := range objects {
+		if strings.Contains(object.Key, due.ID.String()) {
+			t.Errorf("stored object %q of the purged account is left", object.Key)
+		}
+	}
+	if sessions, _ := cache.GetUserSessions(due.ID.String()); len(sessions) != 0 {
+		t.Errorf("%d sessions of the purged account are left", len(sessions))
+	}
+	if updated, _ := models.GetTag(tag.ID); updated.PortfolioCount != 1 {
+		t.Errorf("tag counts %d portfolios after the purge, want 1", updated.PortfolioCount)
+	}
+
+	// An account still in its grace period is left alone.
+	if _, err := models.GetUser(waiting.ID); err != nil {
+		t.Fatalf("an account in its grace period was purged: %v", err)
+	}
+	if count := countRows(t, &models.Asset{}, "user_id = ?", waiting.ID); count != 1 {
+		t.Errorf("the waiting account has %d assets, want 1", count)
+	}
+	if len(objects) != 3 {
+		t.Errorf("%d stored objects are left, want the waiting account's 3", len(objects))
+	}
+}
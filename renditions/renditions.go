@@ -0,0 +1,181 @@
+package renditions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // registers the WebP decoder with image.Decode
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"lightRoom/models"
+	"lightRoom/storage"
+	"lightRoom/utils"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxSourcePixels guards against decompression bombs, 50 megapixels is plenty for a photo.
+const maxSourcePixels = 50_000_000
+
+const jpegQuality = 85
+
+var ErrUnsupportedImage = errors.New("renditions: unsupported image")
+
+// Size is a named rendition, the image is scaled so its longest edge is at most MaxEdge.
+type Size struct {
+	Name    string
+	MaxEdge int
+}
+
+// Sizes are the renditions made for every image, parsed from utils.Settings.RenditionSizes.
+var Sizes []Size
+
+func Init() {
+	var err error
+	Sizes, err = ParseSizes(utils.Settings.RenditionSizes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = watermarkInit(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ParseSizes reads a list such as "thumb:320,medium:960".
+func ParseSizes(rawSizes string) ([]Size, error) {
+	var sizes []Size
+	for _, rawSize := range strings.Split(rawSizes, ",") {
+		name, rawEdge, found := strings.Cut(strings.TrimSpace(rawSize), ":")
+		maxEdge, err := strconv.Atoi(rawEdge)
+		if !found || name == "" || err != nil || maxEdge <= 0 {
+			return nil, fmt.Errorf("renditions: invalid size %q, expected name:pixels", rawSize)
+		}
+		sizes = append(sizes, Size{Name: name, MaxEdge: maxEdge})
+	}
+	return sizes, nil
+}
+
+// Supported reports whether renditions can be made for contentType.
+func Supported(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Decode reads a JPEG, PNG or WebP image, refusing anything absurdly large before decoding it.
+func Decode(source io.ReadSeeker) (image.Image, string, error) {
+	config, _, err := image.DecodeConfig(source)
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxSourcePixels {
+		return nil, "", fmt.Errorf("renditions: image is %dx%d which is too large", config.Width, config.Height)
+	}
+	if _, err = source.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	return image.Decode(source)
+}
+
+// Resize scales img down so its longest edge is maxEdge, smaller images are never upscaled.
+func Resize(img image.Image, maxEdge int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxEdge && height <= maxEdge {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxEdge/width)
+		width = maxEdge
+	} else {
+		width = max(1, width*maxEdge/height)
+		height = maxEdge
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Over, nil)
+	return resized
+}
+
+// Encode writes PNG sources back out as PNG to keep transparency and everything else as JPEG.
+func Encode(writer io.Writer, img image.Image, format string) (string, error) {
+	if format == "png" {
+		return "image/png", png.Encode(writer, img)
+	}
+	return "image/jpeg", jpeg.Encode(writer, img, &jpeg.Options{Quality: jpegQuality})
+}
+
+// renditionKey places renditions next to their original, env/PORTFOLIO/owner/hash/thumb.jpg.
+func renditionKey(originalKey, name, contentType string) string {
+	extension := ".jpg"
+	if contentType == "image/png" {
+		extension = ".png"
+	}
+	base := strings.TrimSuffix(originalKey, path.Ext(originalKey))
+	return fmt.Sprintf("%s/%s%s", base, name, extension)
+}
+
+// Create makes every configured size of asset from source, stores them through
+// storage.Store and records them against the asset.
+func Create(ctx context.Context, asset models.Asset, source io.ReadSeeker) ([]models.AssetRendition, error) {
+	var created []models.AssetRendition
+
+	img, format, err := Decode(source)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, size := range Sizes {
+		resized := Resize(img, size.MaxEdge)
+
+		var encoded bytes.Buffer
+		contentType, err := Encode(&encoded, resized, format)
+		if err != nil {
+			return created, err
+		}
+
+		key := renditionKey(asset.StorageKey, size.Name, contentType)
+		encodedSize := int64(encoded.Len())
+		if err = storage.Store.Put(ctx, key, &encoded, encodedSize, contentType); err != nil {
+			return created, err
+		}
+
+		rendition := models.AssetRendition{
+			ID:          uuid.New(),
+			AssetID:     asset.ID,
+			Name:        size.Name,
+			StorageKey:  key,
+			URL:         storage.Store.URL(key),
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+			Size:        encodedSize,
+			ContentType: contentType,
+		}
+		if err = models.CreateAssetRendition(rendition); err != nil {
+			_ = storage.Store.Delete(ctx, key)
+			return created, err
+		}
+		created = append(created, rendition)
+	}
+	return created, nil
+}
+
+// Delete removes the stored files of every rendition of asset.
+func Delete(ctx context.Context, asset models.Asset) error {
+	for _, rendition := range asset.Renditions {
+		if err := storage.Store.Delete(ctx, rendition.StorageKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package renditions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"io"
+	"lightRoom/models"
+	"lightRoom/storage"
+	"lightRoom/utils"
+	"os"
+	"path"
+)
+
+// WatermarkRenditionName is the rendition non-purchasers are served for a paywalled image.
+const WatermarkRenditionName = "watermarked"
+
+var (
+	watermarkFont *opentype.Font
+	watermarkLogo image.Image
+)
+
+// watermarkInit loads the overlay font and, when configured, the logo overlay.
+func watermarkInit() error {
+	var err error
+	watermarkFont, err = opentype.Parse(gobold.TTF)
+	if err != nil {
+		return err
+	}
+
+	if utils.Settings.WatermarkLogoPath == "" {
+		return nil
+	}
+	logoFile, err := os.Open(utils.Settings.WatermarkLogoPath)
+	if err != nil {
+		return err
+	}
+	defer logoFile.Close()
+	watermarkLogo, _, err = Decode(logoFile)
+	if err != nil {
+		return fmt.Errorf("renditions: watermark logo: %w", err)
+	}
+	return nil
+}
+
+// previewEdge is the longest edge of the watermarked preview, taken from the configured sizes.
+func previewEdge() int {
+	for _, size := range Sizes {
+		if size.Name == utils.Settings.WatermarkPreviewSize {
+			return size.MaxEdge
+		}
+	}
+	return Sizes[0].MaxEdge
+}
+
+// Watermark draws the configured logo in the centre of img and tiles the configured text across it.
+func Watermark(img image.Image) image.Image {
+	bounds := img.Bounds()
+	marked := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(marked, marked.Bounds(), img, bounds.Min, draw.Src)
+
+	opacity := uint8(utils.Settings.WatermarkOpacity * 255 / 100)
+
+	if watermarkLogo != nil {
+		logo := Resize(watermarkLogo, max(1, min(bounds.Dx(), bounds.Dy())/3))
+		logoBounds := logo.Bounds()
+		offset := image.Pt((bounds.Dx()-logoBounds.Dx())/2, (bounds.Dy()-logoBounds.Dy())/2)
+		draw.DrawMask(marked, logoBounds.Sub(logoBounds.Min).Add(offset), logo, logoBounds.Min,
+			image.NewUniform(color.Alpha{A: opacity}), image.Point{}, draw.Over)
+	}
+
+	if utils.Settings.WatermarkText != "" {
+		tileText(marked, utils.Settings.WatermarkText, opacity)
+	}
+	return marked
+}
+
+// tileText repeats text in staggered rows so it cannot simply be cropped away.
+func tileText(dst *image.RGBA, text string, opacity uint8) {
+	width, height := dst.Bounds().Dx(), dst.Bounds().Dy()
+	face, err := opentype.NewFace(watermarkFont, &opentype.FaceOptions{
+		Size: float64(max(12, min(width, height)/10)),
+		DPI:  72,
+	})
+	if err != nil {
+		return
+	}
+	defer face.Close()
+
+	drawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(color.NRGBA{R: 255, G: 255, B: 255, A: opacity}),
+		Face: face,
+	}
+	textWidth := drawer.MeasureString(text).Ceil()
+	lineHeight := face.Metrics().Height.Ceil()
+	columnGap := textWidth + lineHeight*2
+	rowGap := lineHeight * 3
+
+	for row, y := 0, lineHeight; y < height+lineHeight; row, y = row+1, y+rowGap {
+		startX := -(row % 2) * columnGap / 2
+		for x := startX; x < width; x += columnGap {
+			drawer.Dot = fixed.P(x, y)
+			drawer.DrawString(text)
+		}
+	}
+}
+
+// CreateWatermarkedPreview stores a downsized, watermarked copy of a private asset
+// under a random public key and records it as the asset's watermarked rendition.
+func CreateWatermarkedPreview(ctx context.Context, asset models.Asset, source io.ReadSeeker) (models.AssetRendition, error) {
+	img, format, err := Decode(source)
+	if err != nil {
+		return models.AssetRendition{}, err
+	}
+
+	preview := Watermark(Resize(img, previewEdge()))
+
+	var encoded bytes.Buffer
+	contentType, err := Encode(&encoded, preview, format)
+	if err != nil {
+		return models.AssetRendition{}, err
+	}
+
+	// The private key carries the content hash, so the public copy sits beside it under a random name
+	// that gives nothing away about where the original is.
+	previewBase := path.Join(path.Dir(storage.PublicKey(asset.StorageKey)), uuid.NewString())
+	key := renditionKey(previewBase, WatermarkRenditionName, contentType)
+	encodedSize := int64(encoded.Len())
+	if err = storage.Store.Put(ctx, key, &encoded, encodedSize, contentType); err != nil {
+		return models.AssetRendition{}, err
+	}
+
+	rendition := models.AssetRendition{
+		ID:          uuid.New(),
+		AssetID:     asset.ID,
+		Name:        WatermarkRenditionName,
+		StorageKey:  key,
+		URL:         storage.Store.URL(key),
+		Width:       preview.Bounds().Dx(),
+		Height:      preview.Bounds().Dy(),
+		Size:        encodedSize,
+		ContentType: contentType,
+	}
+	if err = models.CreateAssetRendition(rendition); err != nil {
+		_ = storage.Store.Delete(ctx, key)
+		return models.AssetRendition{}, err
+	}
+	return rendition, nil
+}
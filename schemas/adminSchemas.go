@@ -0,0 +1,6 @@
+package schemas
+
+// Role Update Payload
+type RolePayload struct {
+	Role string `json:"role" validate:"required,oneof=User Admin"`
+}
@@ -0,0 +1,16 @@
+package schemas
+
+import "lightRoom/models"
+
+// Create API Key Payload
+type CreateAPIKeyPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=read upload manage-portfolios delete-files admin"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,gte=1,lte=365"`
+}
+
+// Created API Key Payload, the key itself is only ever shown here
+type APIKeyCreatedPayload struct {
+	Key    string        `json:"key"`
+	APIKey models.APIKey `json:"api_key"`
+}
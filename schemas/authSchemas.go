@@ -1,40 +1,49 @@
 package schemas
 
+import "encoding/json"
+
+// Password limits follow NIST SP 800-63B: at least 8 characters for new passwords and room for
+// passphrases. Passwords that are only checked, not set, just need to be present.
+
 // Registration Payload
 type UserPayload struct {
 	Name     string `json:"name" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"gt=1,lte=15"`
+	Password string `json:"password" validate:"min=8,max=128"`
 }
 
-// User Update Payload
+// User Update Payload, the password and email have their own flows that check who is asking
 type UserUpdatePayload struct {
-	Name       *string `json:"name"`
-	Password   *string `json:"password" validate:"gt=1,lte=15"`
-	IsVerified *bool   `json:"is_verified"`
+	Name *string `json:"name" validate:"omitnil,min=1,max=100"`
 }
 
 // Change Password Payload
 type ChangePasswordPayload struct {
-	OldPassword string `json:"old_password" validate:"gt=1,lte=15"`
-	NewPassword string `json:"new_password" validate:"gt=1,lte=15"`
+	OldPassword string `json:"old_password" validate:"required,max=128"`
+	NewPassword string `json:"new_password" validate:"min=8,max=128"`
+}
+
+// Change Email Payload
+type ChangeEmailPayload struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+	Password string `json:"password" validate:"required,max=128"`
 }
 
 // Password Reset Payload
 type PasswordResetPayload struct {
 	Token    string `json:"token" validate:"required"`
-	Password string `json:"password" validate:"gt=1,lte=15"`
+	Password string `json:"password" validate:"min=8,max=128"`
 }
 
 // Login Payload
 type LoginPayload struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"gt=1,lte=15"`
+	Password string `json:"password" validate:"required,max=128"`
 }
 
 // Token Payload
 type TokenPayload struct {
-	Token string `json:"token" validate:"required,token"`
+	Token string `json:"token" validate:"required"`
 }
 
 // AccessToken Payload
@@ -42,17 +51,72 @@ type AccessTokenPayload struct {
 	AccessToken string `json:"access_token" validate:"required"`
 }
 
-// LOGOUT PAYLOAD
-type LogoutPayload struct {
+// Token Pair Payload
+type TokenPairPayload struct {
 	AccessTokenPayload
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+// LOGOUT PAYLOAD
+type LogoutPayload struct {
+	TokenPairPayload
+}
+
 type EmailPayload struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
 type AccessPayload struct {
-	LogoutPayload
+	TokenPairPayload
 	AccountVerified bool `json:"account_verified"`
 }
+
+// TOTP Enrollment Payload
+type TotpEnrollmentPayload struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// Two Factor Code Payload, a TOTP code or a recovery code
+type MfaCodePayload struct {
+	Code string `json:"code" validate:"required,lte=16"`
+}
+
+// Recovery Codes Payload
+type RecoveryCodesPayload struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Two Factor Status Payload
+type MfaStatusPayload struct {
+	TotpEnabled            bool  `json:"totp_enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// MFA Challenge Payload, returned by login instead of tokens when 2FA is enabled
+type MfaChallengePayload struct {
+	MfaRequired bool   `json:"mfa_required"`
+	MfaToken    string `json:"mfa_token"`
+}
+
+// MFA Login Payload
+type MfaLoginPayload struct {
+	MfaToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,lte=16"`
+}
+
+// WebAuthn Ceremony Payload, options are handed to the browser's credentials API as they are
+type WebAuthnCeremonyPayload struct {
+	CeremonyID string          `json:"ceremony_id"`
+	Options    json.RawMessage `json:"options" swaggertype:"object"`
+}
+
+// OAuth Start Payload
+type OAuthStartPayload struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// Magic Link Payload
+type MagicLinkPayload struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,14 @@
+package schemas
+
+import "lightRoom/models"
+
+// Checkout Payload
+type CheckoutPayload struct {
+	PortfolioIDs []string `json:"portfolio_ids" validate:"required,min=1,max=50,dive,uuid"`
+}
+
+// Checkout Response Payload
+type CheckoutResponsePayload struct {
+	Order       models.Order `json:"order"`
+	CheckoutURL string       `json:"checkout_url"`
+}
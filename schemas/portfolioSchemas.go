@@ -0,0 +1,31 @@
+package schemas
+
+import "time"
+
+// Portfolio Create Payload
+type PortfolioPayload struct {
+	Title           string   `json:"name" validate:"required,lte=255"`
+	Description     string   `json:"description"`
+	Price           int      `json:"price" validate:"gte=0"`
+	Tags            []string `json:"tags" validate:"dive,uuid"`
+	Images          []string `json:"images" validate:"dive,url"`
+	PaywalledImages []string `json:"paywalled_images" validate:"dive,url"`
+}
+
+// Portfolio Update Payload
+type PortfolioUpdatePayload struct {
+	Title           *string   `json:"name" validate:"omitnil,gt=0,lte=255"`
+	Description     *string   `json:"description"`
+	Price           *int      `json:"price" validate:"omitnil,gte=0"`
+	Tags            *[]string `json:"tags" validate:"omitnil,dive,uuid"`
+	Images          *[]string `json:"images" validate:"omitnil,dive,url"`
+	PaywalledImages *[]string `json:"paywalled_images" validate:"omitnil,dive,url"`
+}
+
+// Download URL Payload
+type DownloadURLPayload struct {
+	AssetID   string    `json:"asset_id"`
+	FileName  string    `json:"file_name"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
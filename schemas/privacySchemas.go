@@ -0,0 +1,23 @@
+package schemas
+
+import (
+	"lightRoom/models"
+	"time"
+)
+
+// Delete Account Payload, accounts without a password may send a second factor code instead
+type DeleteAccountPayload struct {
+	Password string `json:"password" validate:"omitempty,max=128"`
+	Code     string `json:"code" validate:"omitempty,lte=16"`
+}
+
+// Account Deletion Payload
+type AccountDeletionPayload struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// Data Export Payload, the download link is only set once the export is ready
+type DataExportPayload struct {
+	models.DataExport
+	DownloadURL string `json:"download_url,omitempty"`
+}
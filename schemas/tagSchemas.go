@@ -0,0 +1,11 @@
+package schemas
+
+// Tag Create Payload
+type TagPayload struct {
+	Title string `json:"title" validate:"required,lte=64"`
+}
+
+// Portfolio Tags Payload
+type PortfolioTagsPayload struct {
+	Tags []string `json:"tags" validate:"required,min=1,dive,uuid"`
+}
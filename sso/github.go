@@ -0,0 +1,89 @@
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"net/http"
+	"strconv"
+)
+
+const githubApiBase = "https://api.github.com"
+
+// GithubProvider signs users in with GitHub, which speaks OAuth2 but not OpenID Connect,
+// so the identity comes from its REST API instead of an ID token.
+type GithubProvider struct {
+	config oauth2.Config
+}
+
+func newGithubProvider(config oauth2.Config) *GithubProvider {
+	config.Endpoint = github.Endpoint
+	config.Scopes = []string{"read:user", "user:email"}
+	return &GithubProvider{config: config}
+}
+
+func (provider *GithubProvider) Name() string {
+	return "github"
+}
+
+func (provider *GithubProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	return provider.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func githubGet(client *http.Client, path string, target interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, githubApiBase+path, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("sso: github %v returned %d", path, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+func (provider *GithubProvider) Identity(ctx context.Context, code, nonce, verifier string) (Identity, error) {
+	token, err := provider.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, err
+	}
+	client := provider.config.Client(ctx, token)
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err = githubGet(client, "/user", &user); err != nil {
+		return Identity{}, err
+	}
+
+	// The profile email may be unverified or hidden, only the verified primary address is trusted.
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err = githubGet(client, "/user/emails", &emails); err != nil {
+		return Identity{}, err
+	}
+
+	identity := Identity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			identity.Email = email.Email
+			identity.EmailVerified = true
+		}
+	}
+	return identity, nil
+}
@@ -0,0 +1,91 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"sync"
+)
+
+// OIDCProvider signs users in with any OpenID Connect issuer, found through discovery.
+type OIDCProvider struct {
+	name   string
+	issuer string
+	config oauth2.Config
+
+	mutex    sync.Mutex
+	verifier *oidc.IDTokenVerifier
+}
+
+func newOIDCProvider(name, issuer string, config oauth2.Config) *OIDCProvider {
+	config.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	return &OIDCProvider{name: name, issuer: issuer, config: config}
+}
+
+func (provider *OIDCProvider) Name() string {
+	return provider.name
+}
+
+// discover fetches the issuer's metadata on first use, a failed attempt is retried
+// on the next sign-in rather than keeping the provider down until a restart.
+func (provider *OIDCProvider) discover(ctx context.Context) (oauth2.Config, *oidc.IDTokenVerifier, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.verifier == nil {
+		discovered, err := oidc.NewProvider(ctx, provider.issuer)
+		if err != nil {
+			return oauth2.Config{}, nil, err
+		}
+		provider.config.Endpoint = discovered.Endpoint()
+		provider.verifier = discovered.Verifier(&oidc.Config{ClientID: provider.config.ClientID})
+	}
+	return provider.config, provider.verifier, nil
+}
+
+func (provider *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	config, _, err := provider.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (provider *OIDCProvider) Identity(ctx context.Context, code, nonce, verifier string) (Identity, error) {
+	config, idTokenVerifier, err := provider.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, errors.New("sso: token response has no id_token")
+	}
+	idToken, err := idTokenVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, err
+	}
+	if idToken.Nonce != nonce {
+		return Identity{}, errors.New("sso: id_token nonce does not match")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err = idToken.Claims(&claims); err != nil {
+		return Identity{}, err
+	}
+	return Identity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
@@ -0,0 +1,82 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/oauth2"
+	"lightRoom/utils"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("sso: provider is not configured")
+	ErrEmailNotVerified = errors.New("sso: provider did not return a verified email")
+)
+
+// Identity is what a provider tells us about the person who signed in.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is one configured identity provider.
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where the browser is sent to sign in, state and nonce come back with the code.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Identity exchanges the code, using the PKCE verifier, and returns the verified identity.
+	Identity(ctx context.Context, code, nonce, verifier string) (Identity, error)
+}
+
+// Providers holds the providers that have credentials configured, keyed by name.
+var Providers = map[string]Provider{}
+
+func redirectURL(name string) string {
+	return strings.TrimSuffix(utils.Settings.OAuthCallbackBaseUrl, "/") + "/" + name + "/callback"
+}
+
+func Init() {
+	settings := utils.Settings
+	if settings.GoogleClientID != "" {
+		Providers["google"] = newOIDCProvider("google", "https://accounts.google.com", oauth2.Config{
+			ClientID:     settings.GoogleClientID,
+			ClientSecret: settings.GoogleClientSecret,
+			RedirectURL:  redirectURL("google"),
+		})
+	}
+	if settings.GithubClientID != "" {
+		Providers["github"] = newGithubProvider(oauth2.Config{
+			ClientID:     settings.GithubClientID,
+			ClientSecret: settings.GithubClientSecret,
+			RedirectURL:  redirectURL("github"),
+		})
+	}
+	if settings.OidcClientID != "" {
+		Providers[settings.OidcProviderName] = newOIDCProvider(settings.OidcProviderName, settings.OidcIssuerUrl, oauth2.Config{
+			ClientID:     settings.OidcClientID,
+			ClientSecret: settings.OidcClientSecret,
+			RedirectURL:  redirectURL(settings.OidcProviderName),
+		})
+	}
+}
+
+func Get(name string) (Provider, error) {
+	provider, ok := Providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Names lists the configured providers in a stable order.
+func Names() []string {
+	names := make([]string, 0, len(Providers))
+	for name := range Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStorage keeps objects as plain files below a root directory,
+// which is handy on laptops and in CI where there is no bucket.
+type LocalStorage struct {
+	urlSigner
+	rootDir string
+	baseUrl string
+}
+
+func NewLocalStorage(rootDir, baseUrl, signingSecret string) (*LocalStorage, error) {
+	absoluteRoot, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(absoluteRoot, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{
+		urlSigner: urlSigner{secret: []byte(signingSecret), baseUrl: baseUrl},
+		rootDir:   absoluteRoot,
+		baseUrl:   baseUrl,
+	}, nil
+}
+
+func (local *LocalStorage) filePath(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(local.rootDir, filepath.FromSlash(key)), nil
+}
+
+// detectContentType guesses from the extension first and the leading bytes second.
+func detectContentType(key string, file io.ReadSeeker) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	buffer := make([]byte, 512)
+	read, _ := io.ReadFull(file, buffer)
+	_, _ = file.Seek(0, io.SeekStart)
+	return http.DetectContentType(buffer[:read])
+}
+
+func (local *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	filePath, err := local.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see half an upload.
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err = io.Copy(tempFile, body); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err = tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), filePath)
+}
+
+func (local *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	filePath, err := local.filePath(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+	return file, ObjectInfo{
+		Key:          key,
+		Size:         fileInfo.Size(),
+		ContentType:  detectContentType(key, file),
+		LastModified: fileInfo.ModTime(),
+	}, nil
+}
+
+func (local *LocalStorage) Delete(ctx context.Context, key string) error {
+	filePath, err := local.filePath(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (local *LocalStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	reader, objectInfo, err := local.Get(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	reader.Close()
+	return objectInfo, nil
+}
+
+func (local *LocalStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	err := filepath.WalkDir(local.rootDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+		relativePath, err := filepath.Rel(local.rootDir, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relativePath)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fileInfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			Size:         fileInfo.Size(),
+			ContentType:  mime.TypeByExtension(path.Ext(key)),
+			LastModified: fileInfo.ModTime(),
+		})
+		return nil
+	})
+	return objects, err
+}
+
+// PresignGet returns an HMAC signed URL that the API's file route verifies before serving.
+func (local *LocalStorage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return local.signedURL("GET", key, expiry)
+}
+
+func (local *LocalStorage) PresignPut(ctx context.Context, key, contentType string, expiry time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+func (local *LocalStorage) URL(key string) string {
+	return joinURL(local.baseUrl, key)
+}
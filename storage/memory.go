@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+// MemoryStorage keeps objects in a map, it is meant for tests and throwaway environments.
+type MemoryStorage struct {
+	urlSigner
+	mutex   sync.RWMutex
+	objects map[string]memoryObject
+	baseUrl string
+}
+
+func NewMemoryStorage(baseUrl, signingSecret string) *MemoryStorage {
+	return &MemoryStorage{
+		urlSigner: urlSigner{secret: []byte(signingSecret), baseUrl: baseUrl},
+		objects:   map[string]memoryObject{},
+		baseUrl:   baseUrl,
+	}
+}
+
+func (memory *MemoryStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	memory.objects[key] = memoryObject{data: data, contentType: contentType, modified: time.Now()}
+	return nil
+}
+
+func (memory *MemoryStorage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	memory.mutex.RLock()
+	defer memory.mutex.RUnlock()
+
+	object, ok := memory.objects[key]
+	if !ok {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(object.data)), object.info(key), nil
+}
+
+func (memory *MemoryStorage) Delete(ctx context.Context, key string) error {
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+
+	delete(memory.objects, key)
+	return nil
+}
+
+func (memory *MemoryStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	memory.mutex.RLock()
+	defer memory.mutex.RUnlock()
+
+	object, ok := memory.objects[key]
+	if !ok {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return object.info(key), nil
+}
+
+func (memory *MemoryStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	memory.mutex.RLock()
+	defer memory.mutex.RUnlock()
+
+	var objects []ObjectInfo
+	for key, object := range memory.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, object.info(key))
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (memory *MemoryStorage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return memory.signedURL("GET", key, expiry)
+}
+
+func (memory *MemoryStorage) PresignPut(ctx context.Context, key, contentType string, expiry time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+func (memory *MemoryStorage) URL(key string) string {
+	return joinURL(memory.baseUrl, key)
+}
+
+func (object memoryObject) info(key string) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         int64(len(object.data)),
+		ContentType:  object.contentType,
+		LastModified: object.modified,
+	}
+}
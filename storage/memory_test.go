@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoragePutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStorage("https://files.example.com", "test-secret")
+
+	err := memory.Put(ctx, "dev/PORTFOLIO/owner/a.jpg", strings.NewReader("image bytes"), 11, "image/jpeg")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	body, info, err := memory.Get(ctx, "dev/PORTFOLIO/owner/a.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "image bytes" {
+		t.Errorf("Get returned %q, want %q", data, "image bytes")
+	}
+	if info.Size != 11 || info.ContentType != "image/jpeg" || info.Key != "dev/PORTFOLIO/owner/a.jpg" {
+		t.Errorf("Get info = %+v", info)
+	}
+
+	if _, err = memory.Stat(ctx, "dev/PORTFOLIO/owner/a.jpg"); err != nil {
+		t.Errorf("Stat: %v", err)
+	}
+
+	if err = memory.Delete(ctx, "dev/PORTFOLIO/owner/a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err = memory.Get(ctx, "dev/PORTFOLIO/owner/a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete err = %v, want ErrNotFound", err)
+	}
+	if _, err = memory.Stat(ctx, "dev/PORTFOLIO/owner/a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStoragePutRejectsBadKeys(t *testing.T) {
+	memory := NewMemoryStorage("https://files.example.com", "test-secret")
+
+	for _, key := range []string{"", "/", "a//b", "a/../b", "./a"} {
+		err := memory.Put(context.Background(), key, strings.NewReader("x"), 1, "text/plain")
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) err = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestMemoryStorageList(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStorage("https://files.example.com", "test-secret")
+	for _, key := range []string{"dev/b.png", "dev/a.png", "prod/c.png"} {
+		if err := memory.Put(ctx, key, strings.NewReader("x"), 1, "image/png"); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+
+	objects, err := memory.List(ctx, "dev/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 2 || objects[0].Key != "dev/a.png" || objects[1].Key != "dev/b.png" {
+		t.Errorf("List(dev/) = %+v, want dev/a.png and dev/b.png in order", objects)
+	}
+}
+
+func TestMemoryStoragePresignGet(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStorage("https://files.example.com/", "test-secret")
+
+	signedURL, err := memory.PresignGet(ctx, "private/a.jpg", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	parsed, err := url.Parse(signedURL)
+	if err != nil {
+		t.Fatalf("PresignGet returned an unparsable URL %q: %v", signedURL, err)
+	}
+	if parsed.Path != "/private/a.jpg" {
+		t.Errorf("signed URL path = %q, want /private/a.jpg", parsed.Path)
+	}
+
+	query := parsed.Query()
+	if !memory.VerifySignedGet("private/a.jpg", query.Get("expires"), query.Get("signature")) {
+		t.Error("VerifySignedGet rejected a fresh signed URL")
+	}
+	if memory.VerifySignedGet("private/b.jpg", query.Get("expires"), query.Get("signature")) {
+		t.Error("VerifySignedGet accepted the signature for another key")
+	}
+
+	expiredURL, _ := memory.PresignGet(ctx, "private/a.jpg", -time.Minute)
+	expired, _ := url.Parse(expiredURL)
+	if memory.VerifySignedGet("private/a.jpg", expired.Query().Get("expires"), expired.Query().Get("signature")) {
+		t.Error("VerifySignedGet accepted an expired URL")
+	}
+
+	if _, err = memory.PresignPut(ctx, "private/a.jpg", "image/jpeg", time.Minute); !errors.Is(err, ErrPresignNotSupported) {
+		t.Errorf("PresignPut err = %v, want ErrPresignNotSupported", err)
+	}
+}
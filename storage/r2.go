@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"io"
+	"time"
+)
+
+type R2Config struct {
+	Bucket          string
+	Endpoint        string
+	AccessKeyID     string
+	AccessSecretKey string
+	// PublicBaseUrl is the CDN address objects are served from.
+	PublicBaseUrl string
+}
+
+// R2Storage talks to Cloudflare R2, or any S3 compatible API, through one shared client.
+type R2Storage struct {
+	client        *s3.Client
+	presignClient *s3.PresignClient
+	bucket        string
+	publicBaseUrl string
+}
+
+func NewR2Storage(r2Config R2Config) (*R2Storage, error) {
+	// Configure credentials using the credentials package
+	r2Credentials := aws.NewCredentialsCache(
+		credentials.NewStaticCredentialsProvider(
+			r2Config.AccessKeyID,     // Cloudflare Access Key ID
+			r2Config.AccessSecretKey, // Cloudflare Secret Access Key
+			"",                       // No session token
+		),
+	)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO(),
+		config.WithCredentialsProvider(r2Credentials),
+		config.WithRegion("auto"), // R2 signs with the "auto" region
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg, func(options *s3.Options) {
+		options.BaseEndpoint = aws.String(r2Config.Endpoint)
+	})
+
+	return &R2Storage{
+		client:        client,
+		presignClient: s3.NewPresignClient(client),
+		bucket:        r2Config.Bucket,
+		publicBaseUrl: r2Config.PublicBaseUrl,
+	}, nil
+}
+
+// isNotFound reports whether err is S3 telling us the key does not exist.
+func isNotFound(err error) bool {
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) {
+		switch apiError.ErrorCode() {
+		case "NotFound", "NoSuchKey":
+			return true
+		}
+	}
+	return false
+}
+
+func (r2 *R2Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(r2.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	}
+	if size >= 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+	_, err = r2.client.PutObject(ctx, input)
+	return err
+}
+
+func (r2 *R2Storage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	output, err := r2.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r2.bucket),
+		Key:    aws.String(key),
+	})
+	if isNotFound(err) {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	return output.Body, ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		LastModified: aws.ToTime(output.LastModified),
+	}, nil
+}
+
+func (r2 *R2Storage) Delete(ctx context.Context, key string) error {
+	_, err := r2.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(r2.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (r2 *R2Storage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	output, err := r2.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(r2.bucket),
+		Key:    aws.String(key),
+	})
+	if isNotFound(err) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		LastModified: aws.ToTime(output.LastModified),
+	}, nil
+}
+
+func (r2 *R2Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	paginator := s3.NewListObjectsV2Paginator(r2.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r2.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (r2 *R2Storage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	request, err := r2.presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r2.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+	return request.URL, nil
+}
+
+func (r2 *R2Storage) PresignPut(ctx context.Context, key, contentType string, expiry time.Duration) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	request, err := r2.presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r2.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+	return request.URL, nil
+}
+
+func (r2 *R2Storage) URL(key string) string {
+	return joinURL(r2.publicBaseUrl, key)
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedQuery(t *testing.T, signer urlSigner, key string, expiry time.Duration) url.Values {
+	t.Helper()
+	signedURL, err := signer.signedURL("GET", key, expiry)
+	if err != nil {
+		t.Fatalf("signedURL(%q): %v", key, err)
+	}
+	parsed, err := url.Parse(signedURL)
+	if err != nil {
+		t.Fatalf("signedURL returned an unparsable URL %q: %v", signedURL, err)
+	}
+	return parsed.Query()
+}
+
+func TestSignedURLVerifies(t *testing.T) {
+	signer := urlSigner{secret: []byte("signing-secret"), baseUrl: "https://api.example.com/files"}
+
+	signedURL, err := signer.signedURL("GET", "/private/dev/a.jpg", time.Minute)
+	if err != nil {
+		t.Fatalf("signedURL: %v", err)
+	}
+	parsed, _ := url.Parse(signedURL)
+	if parsed.Host != "api.example.com" || parsed.Path != "/files/private/dev/a.jpg" {
+		t.Errorf("signed URL = %q, want it below the base URL with the leading slash dropped", signedURL)
+	}
+
+	query := parsed.Query()
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if remaining := time.Until(time.Unix(expires, 0)); remaining <= 0 || remaining > time.Minute+time.Second {
+		t.Errorf("signed URL expires in %v, want about a minute", remaining)
+	}
+	if !signer.VerifySignedGet("private/dev/a.jpg", query.Get("expires"), query.Get("signature")) {
+		t.Error("VerifySignedGet rejected a fresh signature")
+	}
+}
+
+func TestSignedURLExpires(t *testing.T) {
+	signer := urlSigner{secret: []byte("signing-secret"), baseUrl: "https://api.example.com/files"}
+
+	query := signedQuery(t, signer, "private/a.jpg", -time.Second)
+	if signer.VerifySignedGet("private/a.jpg", query.Get("expires"), query.Get("signature")) {
+		t.Error("VerifySignedGet accepted an expired signature")
+	}
+}
+
+func TestSignedURLRejectsTampering(t *testing.T) {
+	signer := urlSigner{secret: []byte("signing-secret"), baseUrl: "https://api.example.com/files"}
+	query := signedQuery(t, signer, "private/a.jpg", time.Minute)
+	expires, signature := query.Get("expires"), query.Get("signature")
+	laterExpires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+	cases := []struct {
+		name      string
+		key       string
+		expires   string
+		signature string
+	}{
+		{"other key", "private/b.jpg", expires, signature},
+		{"extended expiry", "private/a.jpg", laterExpires, signature},
+		{"unparsable expiry", "private/a.jpg", "soon", signature},
+		{"altered signature", "private/a.jpg", expires, "00" + signature[2:]},
+		{"empty signature", "private/a.jpg", expires, ""},
+	}
+	for _, testCase := range cases {
+		if signer.VerifySignedGet(testCase.key, testCase.expires, testCase.signature) {
+			t.Errorf("%s: VerifySignedGet accepted a tampered URL", testCase.name)
+		}
+	}
+
+	otherSigner := urlSigner{secret: []byte("another-secret"), baseUrl: signer.baseUrl}
+	if otherSigner.VerifySignedGet("private/a.jpg", expires, signature) {
+		t.Error("a signature made with one secret verified with another")
+	}
+	if signer.signature("PUT", "private/a.jpg", 0) == signer.signature("GET", "private/a.jpg", 0) {
+		t.Error("signatures for different methods are the same")
+	}
+}
+
+func TestSignedURLRejectsBadKeys(t *testing.T) {
+	signer := urlSigner{secret: []byte("signing-secret"), baseUrl: "https://api.example.com/files"}
+
+	for _, key := range []string{"", "../secret", "private/../../etc/passwd"} {
+		if _, err := signer.signedURL("GET", key, time.Minute); err == nil {
+			t.Errorf("signedURL(%q) succeeded", key)
+		}
+	}
+}
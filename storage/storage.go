@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"lightRoom/utils"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound            = errors.New("storage: object not found")
+	ErrPresignNotSupported = errors.New("storage: presigned urls are not supported by this backend")
+	ErrInvalidKey          = errors.New("storage: invalid object key")
+)
+
+// ObjectInfo describes a stored object without its content.
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Storage is implemented by every backend uploads can be written to.
+// Keys are slash separated paths relative to the backend root.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+	PresignPut(ctx context.Context, key, contentType string, expiry time.Duration) (string, error)
+	// URL is the public address an object is served from.
+	URL(key string) string
+}
+
+// Store is the backend selected through utils.Settings.StorageBackend.
+var Store Storage
+
+func Init() {
+	var err error
+
+	switch utils.Settings.StorageBackend {
+	case "local":
+		Store, err = NewLocalStorage(utils.Settings.LocalStorageDir, utils.Settings.LocalStorageBaseUrl, utils.Settings.StorageSigningSecret)
+	case "memory":
+		Store = NewMemoryStorage(utils.Settings.LocalStorageBaseUrl, utils.Settings.StorageSigningSecret)
+	default:
+		Store, err = NewR2Storage(R2Config{
+			Bucket:          utils.Settings.CloudFlareBucket,
+			Endpoint:        utils.Settings.CloudFlareBucketUrl,
+			AccessKeyID:     utils.Settings.CloudFlareAccessKeyID,
+			AccessSecretKey: utils.Settings.CloudFlareAccessSecretKey,
+			PublicBaseUrl:   utils.Settings.CloudFlareCdnUrl,
+		})
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// contentExtensions maps the content types we sniff to the extension kept on their keys.
+var contentExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// ObjectKey builds the content addressed key an upload is stored under. The owner is part
+// of the key so identical bytes from two users never share, and overwrite, one object.
+func ObjectKey(uploadFilePath string, ownerID uuid.UUID, contentHash, contentType string) string {
+	return fmt.Sprintf("%s/%s/%s/%s%s", utils.Settings.Environment, uploadFilePath, ownerID, contentHash, contentExtensions[contentType])
+}
+
+// PrivateObjectKey is ObjectKey below the private prefix, which is never served publicly.
+func PrivateObjectKey(uploadFilePath string, ownerID uuid.UUID, contentHash, contentType string) string {
+	return utils.Settings.PrivateStoragePrefix + "/" + ObjectKey(uploadFilePath, ownerID, contentHash, contentType)
+}
+
+// IsPrivateKey reports whether key lives below the private prefix.
+func IsPrivateKey(key string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(key, "/"), utils.Settings.PrivateStoragePrefix+"/")
+}
+
+// PublicKey strips the private prefix so derived files of a private object can be stored publicly.
+func PublicKey(key string) string {
+	return strings.TrimPrefix(key, utils.Settings.PrivateStoragePrefix+"/")
+}
+
+// cleanKey rejects keys that are empty or try to climb out of the backend root.
+func cleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+	return key, nil
+}
+
+func joinURL(baseURL, key string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + key
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"github.com/go-chi/jwtauth"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/jwt"
 	"lightRoom/cache"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -14,46 +16,133 @@ import (
 
 var TokenAuth *jwtauth.JWTAuth
 
+// WebAuthn runs the passkey registration and login ceremonies.
+var WebAuthn *webauthn.WebAuthn
+
 func AuthInit() {
 	var JwtSecret string = Settings.JwtSecret
 
 	TokenAuth = jwtauth.New("HS256", []byte(JwtSecret), nil)
+
+	var err error
+	WebAuthn, err = webauthn.New(&webauthn.Config{
+		RPID:          Settings.WebAuthnRPID,
+		RPDisplayName: Settings.WebAuthnRPDisplayName,
+		RPOrigins:     strings.Split(Settings.WebAuthnRPOrigins, ","),
+	})
+	if err != nil {
+		log.Fatalf("Unable to configure WebAuthn %v", err)
+	}
+}
+
+// Token types carried in the typ claim, so neither kind of token is accepted in place of the other.
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected, please log in again")
+
+func tokenClaims(userId uuid.UUID, role, tokenType, tokenID string, expiry time.Duration) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{"user_id": userId, "role": role, "typ": tokenType,
+		jwt.IssuerKey: Settings.TokenIssuer, jwt.AudienceKey: Settings.TokenAudience, jwt.JwtIDKey: tokenID,
+		jwt.IssuedAtKey: now.Unix(), jwt.ExpirationKey: now.Add(expiry).Unix()}
 }
 
-func GenerateAccessToken(userId uuid.UUID) string {
+// validateToken checks expiry, issuer, audience and that the token is of tokenType.
+func validateToken(token jwt.Token, tokenType string) error {
+	return jwt.Validate(token,
+		jwt.WithIssuer(Settings.TokenIssuer),
+		jwt.WithAudience(Settings.TokenAudience),
+		jwt.WithClaimValue("typ", tokenType),
+		jwt.WithRequiredClaim(jwt.JwtIDKey),
+	)
+}
 
-	_, tokenString, _ := TokenAuth.Encode(map[string]interface{}{"user_id": userId,
-		"exp": time.Now().Add(time.Hour * 24).Unix()})
+// GenerateAccessToken signs an access token for sessionID, it stops working once the session is revoked.
+func GenerateAccessToken(userId uuid.UUID, role string, sessionID string) string {
+	claims := tokenClaims(userId, role, accessTokenType, uuid.NewString(), Settings.AccessTokenExpiry)
+	claims["sid"] = sessionID
+
+	_, tokenString, _ := TokenAuth.Encode(claims)
 	return tokenString
 }
 
-func GenerateRefreshToken(userId uuid.UUID) string {
+// GenerateRefreshToken signs a refresh token for sessionID, tokenID is its jti.
+func GenerateRefreshToken(userId uuid.UUID, role string, sessionID string, tokenID string) string {
+	claims := tokenClaims(userId, role, refreshTokenType, tokenID, Settings.RefreshTokenExpiry)
+	claims["sid"] = sessionID
 
-	_, tokenString, _ := TokenAuth.Encode(map[string]interface{}{"user_id": userId,
-		"exp": time.Now().Add(time.Hour * 48).Unix()})
+	_, tokenString, _ := TokenAuth.Encode(claims)
 
 	return tokenString
 }
 
-func VerifyRefreshToken(refreshToken string) (string, error) {
-	//Parsing the Token
-	token, err := jwt.ParseString(refreshToken)
-	if err != nil {
-		return "", errors.New("invalid refresh token")
+// IssueTokens starts a new session for the device making the request and returns an access and a refresh token.
+func IssueTokens(request *http.Request, userId uuid.UUID, role string) (string, string, error) {
+	session := NewSession(request, userId)
+	tokenID := uuid.NewString()
+	if err := cache.CreateSession(session, tokenID, Settings.RefreshTokenExpiry); err != nil {
+		return "", "", err
+	}
+	return GenerateAccessToken(userId, role, session.ID), GenerateRefreshToken(userId, role, session.ID, tokenID), nil
+}
 
+// parseRefreshToken checks the refresh token's signature and expiry and returns its claims.
+func parseRefreshToken(refreshToken string) (map[string]interface{}, error) {
+	token, err := jwtauth.VerifyToken(TokenAuth, refreshToken)
+	if err != nil || validateToken(token, refreshTokenType) != nil {
+		return nil, errors.New("invalid or expired refresh token")
 	}
-	//	validate the token
-	if err = jwt.Validate(token); err != nil {
-		return "", errors.New("invalid or expired refresh token")
+	claims, err := token.AsMap(context.Background())
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
 	}
-	userID, _ := token.Get("user_id")
-	userIDStr, ok := userID.(string)
+	return claims, nil
+}
 
+// RotateRefreshToken exchanges a refresh token for a new access and refresh token pair.
+// The presented token stops working, and presenting it again revokes its whole session.
+// currentRole loads the user's stored role, so role changes reach the new pair, and errors
+// when the user may no longer sign in. It is passed in because utils cannot import models.
+func RotateRefreshToken(request *http.Request, refreshToken string, currentRole func(userID uuid.UUID) (string, error)) (string, string, error) {
+	claims, err := parseRefreshToken(refreshToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	userIDStr, ok := claims["user_id"].(string)
 	if !ok {
-		return "", errors.New("user_id is not a valid string")
+		return "", "", errors.New("user_id is not a valid string")
+	}
+	sessionID, _ := claims["sid"].(string)
+	tokenID, _ := claims[jwt.JwtIDKey].(string)
+	if sessionID == "" || tokenID == "" {
+		return "", "", errors.New("invalid refresh token")
 	}
-	parsedUUID, _ := uuid.Parse(userIDStr)
-	return GenerateAccessToken(parsedUUID), nil
+	parsedUUID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return "", "", errors.New("user_id is not a valid string")
+	}
+	if suspended, _ := cache.IsUserSuspended(userIDStr); suspended {
+		return "", "", errors.New("user account is suspended")
+	}
+	role, err := currentRole(parsedUUID)
+	if err != nil {
+		return "", "", err
+	}
+
+	nextTokenID := uuid.NewString()
+	err = cache.RotateSessionToken(userIDStr, sessionID, tokenID, nextTokenID, ClientIP(request), Settings.RefreshTokenExpiry)
+	if errors.Is(err, cache.ErrRefreshTokenReused) {
+		return "", "", ErrRefreshTokenReused
+	}
+	if err != nil {
+		return "", "", errors.New("invalid or expired refresh token")
+	}
+
+	return GenerateAccessToken(parsedUUID, role, sessionID), GenerateRefreshToken(parsedUUID, role, sessionID, nextTokenID), nil
 }
 
 // Authenticator is a default authentication middleware to enforce access from the
@@ -73,7 +162,7 @@ func LightRoomTicator(next http.Handler) http.Handler {
 
 		}
 
-		if token == nil || jwt.Validate(token) != nil {
+		if token == nil || validateToken(token, accessTokenType) != nil {
 			writer.Header().Set("WWW-Authenticate", "Bearer")
 			writer.Header().Set("Content-Type", "application/json")
 			writer.WriteHeader(http.StatusUnauthorized)
@@ -100,9 +189,67 @@ func LightRoomTicator(next http.Handler) http.Handler {
 		}
 
 		userID, _ := claims["user_id"]
+		if userIDStr, ok := userID.(string); ok {
+			if suspended, _ := cache.IsUserSuspended(userIDStr); suspended {
+				writer.Header().Set("Content-Type", "application/json")
+				writer.WriteHeader(http.StatusForbidden)
+				writer.Write([]byte(`{"detail":"user account is suspended"}`))
+				return
+			}
+		}
+
+		sessionID, _ := claims["sid"].(string)
+		if active, _ := cache.TouchSession(sessionID, ClientIP(request)); !active {
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			writer.Header().Set("Content-Type", "application/json")
+			writer.WriteHeader(http.StatusUnauthorized)
+			writer.Write([]byte(`{"detail":"session has been revoked"}`))
+			return
+		}
 		contxt := context.WithValue(request.Context(), "user_id", userID)
+		contxt = context.WithValue(contxt, "role", claims["role"])
+		contxt = context.WithValue(contxt, "session_id", sessionID)
 		request = request.WithContext(contxt)
 		// Token is authenticated, pass it through
 		next.ServeHTTP(writer, request)
 	})
 }
+
+// ContextUserID reads the user_id placed in the request context by LightRoomTicator.
+func ContextUserID(request *http.Request) (uuid.UUID, error) {
+	userID, ok := request.Context().Value("user_id").(string)
+	if !ok {
+		return uuid.Nil, errors.New("user_id not found in context")
+	}
+	return uuid.Parse(userID)
+}
+
+// RequireRole only lets requests through when the role claim placed in the context by
+// LightRoomTicator is one of roles. It must be mounted after LightRoomTicator.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			role, _ := request.Context().Value("role").(string)
+
+			for _, allowedRole := range roles {
+				if role == allowedRole {
+					next.ServeHTTP(writer, request)
+					return
+				}
+			}
+			JSONResponse(writer, "Forbidden, insufficient role", http.StatusForbidden)
+		})
+	}
+}
+
+// ContextSessionID reads the session_id placed in the request context by LightRoomTicator.
+func ContextSessionID(request *http.Request) string {
+	sessionID, _ := request.Context().Value("session_id").(string)
+	return sessionID
+}
+
+// ContextRole reads the role placed in the request context by LightRoomTicator.
+func ContextRole(request *http.Request) string {
+	role, _ := request.Context().Value("role").(string)
+	return role
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// bloomMagic starts every filter file, followed by a version byte, k, m and the bit array.
+var bloomMagic = [4]byte{'L', 'R', 'B', 'F'}
+
+const (
+	bloomVersion    = 1
+	bloomHeaderSize = 17
+	// maxBloomBits and maxBloomHashes bound what a header may ask for, 8 GiB covers every
+	// breached password published so far at a low false positive rate.
+	maxBloomBits   = 1 << 36
+	maxBloomHashes = 64
+)
+
+var ErrInvalidBloomFilter = errors.New("bloom filter file is not valid")
+
+// BloomFilter answers whether a SHA-1 digest was added to it. It never misses one that was,
+// and wrongly reports one that was not with the false positive rate it was sized for.
+type BloomFilter struct {
+	bits []uint64
+	k    uint32
+	m    uint64
+}
+
+// NewBloomFilter sizes a filter for n digests at false positive rate p.
+func NewBloomFilter(n uint64, p float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{bits: make([]uint64, m/64), k: k, m: m}
+}
+
+// positions derives the k bit positions from the digest by double hashing, a SHA-1 is
+// already uniformly distributed so its halves serve as the two hashes.
+func (filter *BloomFilter) positions(digest [sha1.Size]byte, visit func(uint64) bool) {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	for i := uint64(0); i < uint64(filter.k); i++ {
+		if !visit((h1 + i*h2) % filter.m) {
+			return
+		}
+	}
+}
+
+func (filter *BloomFilter) Add(digest [sha1.Size]byte) {
+	filter.positions(digest, func(position uint64) bool {
+		filter.bits[position/64] |= 1 << (position % 64)
+		return true
+	})
+}
+
+func (filter *BloomFilter) Test(digest [sha1.Size]byte) bool {
+	found := true
+	filter.positions(digest, func(position uint64) bool {
+		found = filter.bits[position/64]&(1<<(position%64)) != 0
+		return found
+	})
+	return found
+}
+
+func (filter *BloomFilter) WriteTo(writer io.Writer) (int64, error) {
+	buffered := bufio.NewWriter(writer)
+	header := make([]byte, 0, bloomHeaderSize)
+	header = append(header, bloomMagic[:]...)
+	header = append(header, bloomVersion)
+	header = binary.BigEndian.AppendUint32(header, filter.k)
+	header = binary.BigEndian.AppendUint64(header, filter.m)
+	if _, err := buffered.Write(header); err != nil {
+		return 0, err
+	}
+	word := make([]byte, 8)
+	for _, bits := range filter.bits {
+		binary.BigEndian.PutUint64(word, bits)
+		if _, err := buffered.Write(word); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(header) + len(filter.bits)*8), buffered.Flush()
+}
+
+// ReadBloomFilter reads a filter written by WriteTo. size is the length of the input, the header
+// must agree with it before the bit array is allocated.
+func ReadBloomFilter(reader io.Reader, size int64) (*BloomFilter, error) {
+	buffered := bufio.NewReader(reader)
+	header := make([]byte, bloomHeaderSize)
+	if _, err := io.ReadFull(buffered, header); err != nil {
+		return nil, ErrInvalidBloomFilter
+	}
+	if [4]byte(header[0:4]) != bloomMagic || header[4] != bloomVersion {
+		return nil, ErrInvalidBloomFilter
+	}
+	filter := &BloomFilter{
+		k: binary.BigEndian.Uint32(header[5:9]),
+		m: binary.BigEndian.Uint64(header[9:17]),
+	}
+	if filter.k == 0 || filter.k > maxBloomHashes || filter.m == 0 || filter.m%64 != 0 || filter.m > maxBloomBits {
+		return nil, ErrInvalidBloomFilter
+	}
+	if size != bloomHeaderSize+int64(filter.m/8) {
+		return nil, ErrInvalidBloomFilter
+	}
+	// Read a word at a time, decoding the whole array in one go would need it in memory twice.
+	filter.bits = make([]uint64, filter.m/64)
+	word := make([]byte, 8)
+	for index := range filter.bits {
+		if _, err := io.ReadFull(buffered, word); err != nil {
+			return nil, ErrInvalidBloomFilter
+		}
+		filter.bits[index] = binary.BigEndian.Uint64(word)
+	}
+	return filter, nil
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func digestOf(value string) [sha1.Size]byte {
+	return sha1.Sum([]byte(value))
+}
+
+func TestBloomFilterRoundTrip(t *testing.T) {
+	filter := NewBloomFilter(1000, 0.001)
+	for index := 0; index < 1000; index++ {
+		filter.Add(digestOf(fmt.Sprintf("added-%d", index)))
+	}
+
+	var encoded bytes.Buffer
+	written, err := filter.WriteTo(&encoded)
+	if err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if written != int64(encoded.Len()) {
+		t.Errorf("WriteTo reported %d bytes, wrote %d", written, encoded.Len())
+	}
+
+	read, err := ReadBloomFilter(bytes.NewReader(encoded.Bytes()), int64(encoded.Len()))
+	if err != nil {
+		t.Fatalf("ReadBloomFilter: %v", err)
+	}
+	if read.k != filter.k || read.m != filter.m {
+		t.Errorf("ReadBloomFilter k, m = %d, %d, want %d, %d", read.k, read.m, filter.k, filter.m)
+	}
+	for index := 0; index < 1000; index++ {
+		if !read.Test(digestOf(fmt.Sprintf("added-%d", index))) {
+			t.Fatalf("added-%d is missing after the round trip", index)
+		}
+	}
+
+	falsePositives := 0
+	for index := 0; index < 10000; index++ {
+		if read.Test(digestOf(fmt.Sprintf("absent-%d", index))) {
+			falsePositives++
+		}
+	}
+	// Sized for 0.1%, ten times that would mean the sizing or the hashing is off.
+	if falsePositives > 100 {
+		t.Errorf("%d of 10000 absent digests tested positive", falsePositives)
+	}
+}
+
+func bloomHeader(k uint32, m uint64) []byte {
+	header := append([]byte{}, bloomMagic[:]...)
+	header = append(header, bloomVersion)
+	header = binary.BigEndian.AppendUint32(header, k)
+	return binary.BigEndian.AppendUint64(header, m)
+}
+
+func TestReadBloomFilterRejectsBadHeaders(t *testing.T) {
+	valid := append(bloomHeader(3, 128), make([]byte, 16)...)
+	if _, err := ReadBloomFilter(bytes.NewReader(valid), int64(len(valid))); err != nil {
+		t.Fatalf("ReadBloomFilter rejected a valid file: %v", err)
+	}
+
+	cases := map[string][]byte{
+		"short header":    valid[:10],
+		"wrong magic":     append([]byte("XXXX"), valid[4:]...),
+		"wrong version":   append(append(append([]byte{}, valid[:4]...), 9), valid[5:]...),
+		"no hashes":       append(bloomHeader(0, 128), make([]byte, 16)...),
+		"too many hashes": append(bloomHeader(maxBloomHashes+1, 128), make([]byte, 16)...),
+		"unaligned bits":  append(bloomHeader(3, 100), make([]byte, 16)...),
+		"truncated bits":  append(bloomHeader(3, 128), make([]byte, 8)...),
+		"trailing bytes":  append(bloomHeader(3, 128), make([]byte, 24)...),
+		// Claims far more bits than the file holds, it must fail before allocating them.
+		"oversized header": append(bloomHeader(3, 1<<62), make([]byte, 16)...),
+		"over the maximum": bloomHeader(3, maxBloomBits+64),
+	}
+	for name, encoded := range cases {
+		_, err := ReadBloomFilter(bytes.NewReader(encoded), int64(len(encoded)))
+		if !errors.Is(err, ErrInvalidBloomFilter) {
+			t.Errorf("%s: err = %v, want ErrInvalidBloomFilter", name, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"lightRoom/cache"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// loginLimit is a backoff ladder, free attempts up to backoffAfter, doubling waits after it
+// and a lockout once lockoutAfter failures have been counted.
+type loginLimit struct {
+	scope        string
+	backoffAfter int64
+	lockoutAfter int64
+}
+
+func accountLoginLimit() loginLimit {
+	return loginLimit{"account", Settings.LoginBackoffAfter, Settings.LoginLockoutAttempts}
+}
+
+// ipLoginLimit gives an address the same number of backoff steps as an account, starting them later
+// because many people can share one address.
+func ipLoginLimit() loginLimit {
+	steps := Settings.LoginLockoutAttempts - Settings.LoginBackoffAfter
+	return loginLimit{"ip", Settings.LoginIPLockoutAttempts - steps, Settings.LoginIPLockoutAttempts}
+}
+
+// mfaLoginLimit counts wrong second factor codes per user, across challenges and the
+// endpoints that ask for a code, with the same ladder as an account.
+func mfaLoginLimit() loginLimit {
+	return loginLimit{"mfa", Settings.LoginBackoffAfter, Settings.LoginLockoutAttempts}
+}
+
+func (limit loginLimit) wait(failures int64) (time.Duration, bool) {
+	if failures >= limit.lockoutAfter {
+		return Settings.LoginLockoutDuration, true
+	}
+	if failures < limit.backoffAfter {
+		return 0, false
+	}
+	shift := failures - limit.backoffAfter
+	if shift > 30 {
+		return Settings.LoginLockoutDuration, false
+	}
+	wait := Settings.LoginBackoffBase << shift
+	if wait > Settings.LoginLockoutDuration {
+		wait = Settings.LoginLockoutDuration
+	}
+	return wait, false
+}
+
+func (limit loginLimit) fail(subject string) (time.Duration, bool) {
+	failures, err := cache.IncrLoginFailures(limit.scope, subject, Settings.LoginFailureWindow)
+	if err != nil {
+		return 0, false
+	}
+	wait, locked := limit.wait(failures)
+	if wait > 0 {
+		_ = cache.BlockLogin(limit.scope, subject, wait)
+	}
+	return wait, locked && failures == limit.lockoutAfter
+}
+
+// LoginRetryAfter returns how long logins to the account from this client are refused, zero when they are not.
+func LoginRetryAfter(email, ip string) time.Duration {
+	accountWait := cache.LoginBlockedFor("account", NormalizeEmail(email))
+	ipWait := cache.LoginBlockedFor("ip", ip)
+	if ipWait > accountWait {
+		return ipWait
+	}
+	return accountWait
+}
+
+// RecordLoginFailure counts a failed login against both the account and the client and returns
+// how long they now have to wait. accountLocked is only true for the failure that locked the account,
+// so the owner is told once per lockout.
+func RecordLoginFailure(email, ip string) (time.Duration, bool) {
+	accountWait, accountLocked := accountLoginLimit().fail(NormalizeEmail(email))
+	ipWait, _ := ipLoginLimit().fail(ip)
+	if ipWait > accountWait {
+		return ipWait, accountLocked
+	}
+	return accountWait, accountLocked
+}
+
+// ClearLoginFailures forgives the account after a successful login, the client's count is kept.
+func ClearLoginFailures(email string) {
+	_ = cache.ClearLoginFailures("account", NormalizeEmail(email))
+}
+
+// MfaRetryAfter returns how long second factor codes for the user are refused, zero when they are not.
+func MfaRetryAfter(userID string) time.Duration {
+	return cache.LoginBlockedFor("mfa", userID)
+}
+
+// RecordMfaFailure counts a wrong second factor code for the user and returns how long they now have to wait.
+func RecordMfaFailure(userID string) time.Duration {
+	wait, _ := mfaLoginLimit().fail(userID)
+	return wait
+}
+
+// ClearMfaFailures forgives the user after a correct second factor code.
+func ClearMfaFailures(userID string) {
+	_ = cache.ClearLoginFailures("mfa", userID)
+}
+
+// SetRetryAfter writes the Retry-After header in whole seconds, rounding up.
+func SetRetryAfter(writer http.ResponseWriter, wait time.Duration) {
+	writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"lightRoom/cache"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// useLoginThrottle points the cache at miniredis and uses a short ladder: free attempts up
+// to 3, then 1s, 2s, 4s... and a 1m lockout at 6 failures, or 10 from one address.
+func useLoginThrottle(t *testing.T) *miniredis.Miniredis {
+	redisServer := miniredis.RunT(t)
+	cache.LRedis = redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+
+	saved := Settings
+	t.Cleanup(func() { Settings = saved })
+	Settings.LoginBackoffAfter = 3
+	Settings.LoginBackoffBase = time.Second
+	Settings.LoginLockoutAttempts = 6
+	Settings.LoginLockoutDuration = time.Minute
+	Settings.LoginIPLockoutAttempts = 10
+	Settings.LoginFailureWindow = time.Hour
+	return redisServer
+}
+
+func TestLoginLimitWait(t *testing.T) {
+	useLoginThrottle(t)
+	limit := accountLoginLimit()
+
+	expected := []struct {
+		failures int64
+		wait     time.Duration
+		locked   bool
+	}{
+		{1, 0, false},
+		{2, 0, false},
+		{3, time.Second, false},
+		{4, 2 * time.Second, false},
+		{5, 4 * time.Second, false},
+		{6, time.Minute, true},
+		{40, time.Minute, true},
+	}
+	for _, step := range expected {
+		wait, locked := limit.wait(step.failures)
+		if wait != step.wait || locked != step.locked {
+			t.Errorf("wait(%d) = %v, %v, want %v, %v", step.failures, wait, locked, step.wait, step.locked)
+		}
+	}
+}
+
+func TestRecordLoginFailureLocksTheAccount(t *testing.T) {
+	useLoginThrottle(t)
+
+	lockouts := 0
+	for attempt := 1; attempt <= 8; attempt++ {
+		// Failures for the same mailbox count together whatever its case.
+		email := "Owner@Example.com"
+		if attempt%2 == 0 {
+			email = "owner@example.com"
+		}
+		wait, locked := RecordLoginFailure(email, "203.0.113.1")
+		if locked {
+			lockouts++
+			if attempt != 6 || wait != time.Minute {
+				t.Errorf("attempt %d locked the account for %v", attempt, wait)
+			}
+		}
+	}
+	if lockouts != 1 {
+		t.Errorf("the account was reported locked %d times, want once", lockouts)
+	}
+	if wait := LoginRetryAfter("OWNER@example.com", "198.51.100.7"); wait <= 0 || wait > time.Minute {
+		t.Errorf("LoginRetryAfter from another address = %v, want the account lockout", wait)
+	}
+
+	ClearLoginFailures("owner@example.com")
+	if wait := LoginRetryAfter("owner@example.com", "198.51.100.7"); wait != 0 {
+		t.Errorf("LoginRetryAfter after ClearLoginFailures = %v, want 0", wait)
+	}
+	// The address keeps its own count, clearing the account does not forgive it.
+	if failures, _ := cache.IncrLoginFailures("ip", "203.0.113.1", time.Hour); failures != 9 {
+		t.Errorf("the address has %d failures counted, want 9", failures)
+	}
+}
+
+func TestRecordLoginFailureThrottlesAnAddress(t *testing.T) {
+	useLoginThrottle(t)
+
+	// Spreading guesses over many accounts still locks the address.
+	var wait time.Duration
+	for attempt := 0; attempt < 10; attempt++ {
+		wait, _ = RecordLoginFailure("user"+strconv.Itoa(attempt)+"@example.com", "203.0.113.1")
+	}
+	if wait != time.Minute {
+		t.Errorf("the tenth failure from one address waits %v, want the lockout", wait)
+	}
+	if LoginRetryAfter("fresh@example.com", "203.0.113.1") <= 0 {
+		t.Error("a locked address could try another account")
+	}
+	if LoginRetryAfter("fresh@example.com", "198.51.100.7") != 0 {
+		t.Error("another address is throttled for an account that never failed")
+	}
+}
+
+func TestLoginLockoutExpires(t *testing.T) {
+	redisServer := useLoginThrottle(t)
+	for attempt := 0; attempt < 6; attempt++ {
+		RecordLoginFailure("owner@example.com", "203.0.113.1")
+	}
+
+	redisServer.FastForward(time.Minute + time.Second)
+	if wait := LoginRetryAfter("owner@example.com", "203.0.113.1"); wait != 0 {
+		t.Errorf("LoginRetryAfter once the lockout has passed = %v, want 0", wait)
+	}
+}
+
+func TestMfaFailuresAreCountedPerUser(t *testing.T) {
+	useLoginThrottle(t)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		RecordMfaFailure("user-1")
+	}
+	if MfaRetryAfter("user-1") <= 0 {
+		t.Error("three wrong codes did not start the backoff")
+	}
+	if MfaRetryAfter("user-2") != 0 {
+		t.Error("another user's codes are throttled")
+	}
+	ClearMfaFailures("user-1")
+	if MfaRetryAfter("user-1") != 0 {
+		t.Error("MfaRetryAfter after ClearMfaFailures is not zero")
+	}
+}
+
+func TestSetRetryAfterRoundsUp(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	SetRetryAfter(recorder, 1500*time.Millisecond)
+	if got := recorder.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+}
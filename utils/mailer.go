@@ -3,18 +3,61 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	"html/template"
 	"log"
+	"mime"
 	"net/smtp"
+	"net/url"
+	"strings"
 )
 
-func SendMail(email []string, payload bytes.Buffer) {
+func SendMail(email []string, subject string, payload bytes.Buffer) {
 	mailHost := Settings.MailHost
 	mailPort := Settings.MailPort
 	address := fmt.Sprintf("%v:%v", mailHost, mailPort)
+
+	// Without the headers clients show the template as plain text and its links cannot be clicked.
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %v\r\n", Settings.MailFrom)
+	fmt.Fprintf(&message, "To: %v\r\n", strings.Join(email, ", "))
+	fmt.Fprintf(&message, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", subject))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
+	message.Write(payload.Bytes())
+
 	// Set up SMTP client and send the email (replace with your actual SMTP settings)
 	auth := smtp.PlainAuth("", Settings.MailUsername, Settings.MailPassword, Settings.MailHost)
-	err := smtp.SendMail(address, auth, Settings.MailFrom, email, payload.Bytes())
+	err := smtp.SendMail(address, auth, Settings.MailFrom, email, message.Bytes())
 	if err != nil {
-		log.Fatal("mail failed to send")
+		// A mail outage must not take the API down with it.
+		log.Printf("mail failed to send %v", err)
 	}
 }
+
+// SendTemplateMail renders one of the templates in templates/ with data and mails it.
+func SendTemplateMail(email []string, subject string, templateFile string, data any) {
+	mailTemplate, err := template.ParseFiles(templateFile)
+	if err != nil {
+		log.Printf("Unable to load mail template %v: %v", templateFile, err)
+		return
+	}
+
+	var payload bytes.Buffer
+	err = mailTemplate.Execute(&payload, data)
+	if err != nil {
+		log.Printf("Unable to render mail template %v: %v", templateFile, err)
+		return
+	}
+	SendMail(email, subject, payload)
+}
+
+// NormalizeEmail is the form addresses are stored and looked up in, so the same mailbox
+// written with different case is one account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// FrontendLink builds a link to a frontend page that hands token back to the API.
+func FrontendLink(path string, token string) string {
+	return fmt.Sprintf("%v/%v?token=%v", strings.TrimRight(Settings.FrontendBaseUrl, "/"), path, url.QueryEscape(token))
+}
@@ -1,13 +1,132 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
 
+// Stored hashes are PHC strings, $argon2id$v=19$m=..,t=..,p=..$salt$hash for argon2id and
+// the $2a$cost$... modular crypt format bcrypt already writes.
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// unusablePasswordPrefix marks accounts that sign in through another provider and have no password.
+const unusablePasswordPrefix = "!sso:"
+
+var (
+	ErrPasswordTooLong = errors.New("password is too long for bcrypt, use at most 72 bytes")
+	errUnknownHash     = errors.New("password hash format is not recognised")
+)
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func configuredArgon2Params() argon2Params {
+	return argon2Params{
+		memory:      uint32(Settings.Argon2Memory),
+		iterations:  uint32(Settings.Argon2Iterations),
+		parallelism: uint8(Settings.Argon2Parallelism),
+	}
+}
+
+// HashPassword hashes with the algorithm and parameters in Settings.
 func HashPassword(password string) (string, error) {
-	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	if Settings.PasswordHashAlgorithm == "bcrypt" {
+		// bcrypt ignores everything after 72 bytes, refuse rather than silently truncate.
+		if len(password) > 72 {
+			return "", ErrPasswordTooLong
+		}
+		bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), Settings.BcryptCost)
+		return string(bcryptHash), err
+	}
+	return hashArgon2(password, configuredArgon2Params())
+}
 
-	return string(bcryptHash), err
+// UnusablePassword is stored for accounts created through social login, no password matches it.
+func UnusablePassword() string {
+	return unusablePasswordPrefix + SecureToken(32)
 }
 
+// IsUnusablePassword reports whether hash was made by UnusablePassword, so the account has no password to ask for.
+func IsUnusablePassword(hash string) bool {
+	return strings.HasPrefix(hash, unusablePasswordPrefix)
+}
+
+func hashArgon2(password string, params argon2Params) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func decodeArgon2(hashedPassword string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	var version int
+
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, errUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errUnknownHash
+	}
+	return params, salt, key, nil
+}
+
+// ComparePasswords checks a password against a hash in any format we have ever stored.
 func ComparePasswords(hashedPassword string, plainPassword string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plainPassword)) == nil
+	switch {
+	case strings.HasPrefix(hashedPassword, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(hashedPassword)
+		if err != nil {
+			return false
+		}
+		candidate := argon2.IDKey([]byte(plainPassword), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(candidate, key) == 1
+	case strings.HasPrefix(hashedPassword, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plainPassword)) == nil
+	default:
+		return false
+	}
+}
+
+// NeedsRehash reports whether a hash that just verified was made with another algorithm or
+// weaker parameters than Settings asks for, so it should be replaced on login.
+func NeedsRehash(hashedPassword string) bool {
+	if strings.HasPrefix(hashedPassword, unusablePasswordPrefix) {
+		return false
+	}
+	if Settings.PasswordHashAlgorithm == "bcrypt" {
+		cost, err := bcrypt.Cost([]byte(hashedPassword))
+		return err != nil || cost != Settings.BcryptCost
+	}
+	params, _, key, err := decodeArgon2(hashedPassword)
+	return err != nil || params != configuredArgon2Params() || len(key) != argon2KeyLength
 }
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// useHashSettings switches the hasher configuration for one test, with argon2 parameters
+// far below production so the tests stay quick.
+func useHashSettings(t *testing.T, algorithm string) {
+	saved := Settings
+	t.Cleanup(func() { Settings = saved })
+	Settings.PasswordHashAlgorithm = algorithm
+	Settings.Argon2Memory = 1024
+	Settings.Argon2Iterations = 1
+	Settings.Argon2Parallelism = 1
+	Settings.BcryptCost = 4
+}
+
+func TestArgon2idHashes(t *testing.T) {
+	useHashSettings(t, "argon2id")
+
+	hashed, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hashed, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("HashPassword = %q, want an argon2id PHC string with the configured parameters", hashed)
+	}
+
+	params, salt, key, err := decodeArgon2(hashed)
+	if err != nil {
+		t.Fatalf("decodeArgon2: %v", err)
+	}
+	if params != configuredArgon2Params() || len(salt) != argon2SaltLength || len(key) != argon2KeyLength {
+		t.Errorf("decodeArgon2 = %+v, %d byte salt, %d byte key", params, len(salt), len(key))
+	}
+
+	if !ComparePasswords(hashed, "correct horse battery staple") {
+		t.Error("ComparePasswords rejected the right password")
+	}
+	if ComparePasswords(hashed, "correct horse battery stapler") {
+		t.Error("ComparePasswords accepted the wrong password")
+	}
+	if other, _ := HashPassword("correct horse battery staple"); other == hashed {
+		t.Error("HashPassword reused a salt")
+	}
+}
+
+func TestDecodeArgon2RejectsMalformedHashes(t *testing.T) {
+	for _, hashed := range []string{
+		"",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$not base64!$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ",
+	} {
+		if _, _, _, err := decodeArgon2(hashed); err == nil {
+			t.Errorf("decodeArgon2(%q) succeeded", hashed)
+		}
+		if ComparePasswords(hashed, "password") {
+			t.Errorf("ComparePasswords accepted a password against %q", hashed)
+		}
+	}
+}
+
+func TestBcryptHashes(t *testing.T) {
+	useHashSettings(t, "bcrypt")
+
+	hashed, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hashed, "$2a$04$") {
+		t.Fatalf("HashPassword = %q, want a cost 4 bcrypt hash", hashed)
+	}
+	if !ComparePasswords(hashed, "correct horse battery staple") || ComparePasswords(hashed, "wrong") {
+		t.Error("ComparePasswords did not tell the right and wrong password apart")
+	}
+	if _, err = HashPassword(strings.Repeat("a", 73)); !errors.Is(err, ErrPasswordTooLong) {
+		t.Errorf("HashPassword of 73 bytes err = %v, want ErrPasswordTooLong", err)
+	}
+
+	// A vector from the OpenBSD bcrypt tests, hashes made elsewhere must keep verifying.
+	if !ComparePasswords("$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U") {
+		t.Error("ComparePasswords rejected the OpenBSD bcrypt vector")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	useHashSettings(t, "argon2id")
+	current, _ := HashPassword("password")
+	weaker, _ := hashArgon2("password", argon2Params{memory: 512, iterations: 1, parallelism: 1})
+	bcryptHash := "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"
+
+	if NeedsRehash(current) {
+		t.Error("NeedsRehash wants to replace a hash made with the current parameters")
+	}
+	if !NeedsRehash(weaker) {
+		t.Error("NeedsRehash kept an argon2id hash with weaker parameters")
+	}
+	if !NeedsRehash(bcryptHash) {
+		t.Error("NeedsRehash kept a bcrypt hash while argon2id is configured")
+	}
+	if NeedsRehash(UnusablePassword()) {
+		t.Error("NeedsRehash wants to replace an unusable password")
+	}
+
+	useHashSettings(t, "bcrypt")
+	Settings.BcryptCost = 5
+	if NeedsRehash(bcryptHash) {
+		t.Error("NeedsRehash wants to replace a bcrypt hash of the configured cost")
+	}
+	if !NeedsRehash(current) {
+		t.Error("NeedsRehash kept an argon2id hash while bcrypt is configured")
+	}
+}
+
+func TestUnusablePassword(t *testing.T) {
+	unusable := UnusablePassword()
+	if !IsUnusablePassword(unusable) || IsUnusablePassword("$2a$05$abc") {
+		t.Error("IsUnusablePassword does not recognise what UnusablePassword makes")
+	}
+	if ComparePasswords(unusable, unusable) || ComparePasswords(unusable, "") {
+		t.Error("ComparePasswords matched an unusable password")
+	}
+}
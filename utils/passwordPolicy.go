@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// minimumIdentifierLength keeps short names and email parts, "al" or "jo", from ruling out half of all passwords.
+const minimumIdentifierLength = 3
+
+var ErrBreachedPassword = errors.New("password has appeared in a data breach, choose another one")
+
+// breachedPasswords is loaded from Settings.BreachedPasswordFilter, nil when no list is configured.
+var breachedPasswords *BloomFilter
+
+// PasswordPolicyInit loads the breached password filter built by cmd/breachfilter.
+func PasswordPolicyInit() {
+	if Settings.BreachedPasswordFilter == "" {
+		return
+	}
+	file, err := os.Open(Settings.BreachedPasswordFilter)
+	if err != nil {
+		log.Fatalf("Unable to open breached password filter %v", err)
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		log.Fatalf("Unable to open breached password filter %v", err)
+	}
+
+	breachedPasswords, err = ReadBloomFilter(file, fileInfo.Size())
+	if err != nil {
+		log.Fatalf("Unable to load breached password filter %v", err)
+	}
+}
+
+// identifiers are the parts of an email and name a password must not contain.
+func identifiers(email, name string) []string {
+	var parts []string
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	parts = append(parts, localPart)
+	parts = append(parts, strings.Fields(strings.ToLower(name))...)
+
+	var kept []string
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= minimumIdentifierLength {
+			kept = append(kept, part)
+		}
+	}
+	return kept
+}
+
+// IsBreachedPassword looks the password up in the breached password filter. A filter can
+// report false positives at the rate it was built for, never false negatives.
+func IsBreachedPassword(password string) bool {
+	if breachedPasswords == nil {
+		return false
+	}
+	return breachedPasswords.Test(sha1.Sum([]byte(password)))
+}
+
+// CheckPasswordPolicy returns why a new password is refused for the account with email and name,
+// the error text is meant for the detail of the response.
+func CheckPasswordPolicy(password, email, name string) error {
+	if utf8.RuneCountInString(password) < Settings.PasswordMinLength {
+		return fmt.Errorf("password must be at least %d characters long", Settings.PasswordMinLength)
+	}
+
+	lowered := strings.ToLower(password)
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, identifier := range identifiers(email, name) {
+		if strings.Contains(lowered, identifier) {
+			if identifier == localPart {
+				return errors.New("password must not contain your email")
+			}
+			return errors.New("password must not contain your name")
+		}
+	}
+
+	if IsBreachedPassword(password) {
+		return ErrBreachedPassword
+	}
+	return nil
+}
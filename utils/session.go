@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"github.com/google/uuid"
+	"lightRoom/cache"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxUserAgentLength = 512
+
+// ClientIP returns the caller's address, the RealIP middleware has already applied
+// X-Forwarded-For and X-Real-IP to RemoteAddr.
+func ClientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// the first match wins, so more specific names come before the ones they contain
+var (
+	browserNames = [][2]string{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"}, {"Safari/", "Safari"}, {"curl/", "curl"},
+	}
+	platformNames = [][2]string{
+		{"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Android", "Android"},
+		{"Windows", "Windows"}, {"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	}
+)
+
+func matchName(userAgent string, names [][2]string) string {
+	for _, name := range names {
+		if strings.Contains(userAgent, name[0]) {
+			return name[1]
+		}
+	}
+	return ""
+}
+
+// DeviceName turns a User-Agent into a short label such as "Firefox on Windows".
+func DeviceName(userAgent string) string {
+	browser := matchName(userAgent, browserNames)
+	platform := matchName(userAgent, platformNames)
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
+
+// NewSession describes the device making the request as a new session for the user.
+func NewSession(request *http.Request, userId uuid.UUID) cache.Session {
+	now := time.Now()
+	userAgent := request.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return cache.Session{
+		ID:         uuid.NewString(),
+		UserID:     userId.String(),
+		Device:     DeviceName(userAgent),
+		IP:         ClientIP(request),
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+}
@@ -1,35 +1,131 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/hkdf"
+	"io"
 	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 type EnvSetting struct {
-	PostgresDsn               string `validate:"required"`
-	Port                      string `validate:"required"`
-	JwtSecret                 string `validate:"required"`
-	RedisDsn                  string `validate:"required"`
-	MailUsername              string `validate:"required"`
-	MailPort                  string `validate:"required"`
-	MailHost                  string `validate:"required"`
-	MailPassword              string `validate:"required"`
-	MailFrom                  string `validate:"required"`
-	Environment               string `validate:"required"`
-	CloudFlareBucket          string `validate:"required"`
-	CloudFlareBucketUrl       string `validate:"required"`
-	CloudFlareAccountID       string `validate:"required"`
-	CloudFlareAccessKeyID     string `validate:"required"`
-	CloudFlareAccessSecretKey string `validate:"required"`
-	CloudFlareCdnUrl          string `validate:"required"`
+	PostgresDsn               string        `validate:"required"`
+	Port                      string        `validate:"required"`
+	JwtSecret                 string        `validate:"required"`
+	RedisDsn                  string        `validate:"required"`
+	MailUsername              string        `validate:"required"`
+	MailPort                  string        `validate:"required"`
+	MailHost                  string        `validate:"required"`
+	MailPassword              string        `validate:"required"`
+	MailFrom                  string        `validate:"required"`
+	Environment               string        `validate:"required"`
+	StorageBackend            string        `validate:"oneof=r2 local memory"`
+	LocalStorageDir           string        `validate:"required_if=StorageBackend local"`
+	LocalStorageBaseUrl       string        `validate:"required_unless=StorageBackend r2"`
+	CloudFlareBucket          string        `validate:"required_if=StorageBackend r2"`
+	CloudFlareBucketUrl       string        `validate:"required_if=StorageBackend r2"`
+	CloudFlareAccountID       string        `validate:"required_if=StorageBackend r2"`
+	CloudFlareAccessKeyID     string        `validate:"required_if=StorageBackend r2"`
+	CloudFlareAccessSecretKey string        `validate:"required_if=StorageBackend r2"`
+	CloudFlareCdnUrl          string        `validate:"required_if=StorageBackend r2"`
+	RenditionSizes            string        `validate:"required"`
+	PrivateStoragePrefix      string        `validate:"required,excludesall=/"`
+	StorageSigningSecret      string        `validate:"required"`
+	DownloadUrlExpiry         time.Duration `validate:"gt=0"`
+	WatermarkText             string
+	WatermarkLogoPath         string        `validate:"required_without=WatermarkText"`
+	WatermarkOpacity          int           `validate:"gte=1,lte=100"`
+	WatermarkPreviewSize      string        `validate:"required"`
+	AppBaseUrl                string        `validate:"required,url"`
+	AccessTokenExpiry         time.Duration `validate:"gt=0"`
+	RefreshTokenExpiry        time.Duration `validate:"gtfield=AccessTokenExpiry"`
+	TokenIssuer               string        `validate:"required"`
+	TokenAudience             string        `validate:"required"`
+	TotpIssuer                string        `validate:"required,excludes=:"`
+	MfaChallengeExpiry        time.Duration `validate:"gt=0"`
+	WebAuthnRPID              string        `validate:"required,hostname"`
+	WebAuthnRPDisplayName     string        `validate:"required"`
+	WebAuthnRPOrigins         string        `validate:"required"`
+	OAuthCallbackBaseUrl      string        `validate:"required,url"`
+	FrontendBaseUrl           string        `validate:"required,url"`
+	VerificationTokenExpiry   time.Duration `validate:"gt=0"`
+	PasswordResetTokenExpiry  time.Duration `validate:"gt=0,lte=24h"`
+	VerificationResendLimit   int64         `validate:"gte=1"`
+	AccountDeletionGrace      time.Duration `validate:"gte=0"`
+	PasswordHashAlgorithm     string        `validate:"oneof=argon2id bcrypt"`
+	Argon2Memory              int           `validate:"gte=19456"`
+	Argon2Iterations          int           `validate:"gte=1"`
+	Argon2Parallelism         int           `validate:"gte=1,lte=255"`
+	BcryptCost                int           `validate:"gte=10,lte=31"`
+	PasswordMinLength         int           `validate:"gte=8,lte=128"`
+	BreachedPasswordFilter    string        `validate:"omitempty,file"`
+	DataExportRetention       time.Duration `validate:"gt=0"`
+	MagicLinkExpiry           time.Duration `validate:"gt=0,lte=1h"`
+	MagicLinkHourlyLimit      int64         `validate:"gte=1"`
+	LoginBackoffAfter         int64         `validate:"gte=1"`
+	LoginBackoffBase          time.Duration `validate:"gt=0"`
+	LoginLockoutAttempts      int64         `validate:"gtfield=LoginBackoffAfter"`
+	LoginLockoutDuration      time.Duration `validate:"gtfield=LoginBackoffBase"`
+	LoginIPLockoutAttempts    int64         `validate:"gtefield=LoginLockoutAttempts"`
+	LoginFailureWindow        time.Duration `validate:"gt=0"`
+	GoogleClientID            string
+	GoogleClientSecret        string `validate:"required_with=GoogleClientID"`
+	GithubClientID            string
+	GithubClientSecret        string `validate:"required_with=GithubClientID"`
+	OidcProviderName          string `validate:"required,alphanum,ne=google,ne=github"`
+	OidcIssuerUrl             string `validate:"required_with=OidcClientID,omitempty,url"`
+	OidcClientID              string
+	OidcClientSecret          string
+	PaymentProvider           string `validate:"required,oneof=stripe fake"`
+	StripeSecretKey           string `validate:"required_if=PaymentProvider stripe"`
+	StripeWebhookSecret       string `validate:"required_if=PaymentProvider stripe"`
+	Currency                  string `validate:"required,len=3,lowercase"`
+	CheckoutSuccessUrl        string `validate:"required,url"`
+	CheckoutCancelUrl         string `validate:"required,url"`
 }
 
 var Settings EnvSetting
 
 var validate *validator.Validate
 
+// errFakePayments refuses the fake payment provider outside local development, it marks
+// orders paid without taking any payment.
+var errFakePayments = errors.New("PAYMENT_PROVIDER=fake settles orders without payment, it is only allowed with ENVIRONMENT=local")
+
+// getEnvDefault reads an optional environment variable, returning fallback when it is unset.
+func getEnvDefault(key, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// deriveSecret expands secret into an independent key for label, so one configured secret
+// can back several uses without a leak of one key giving away the others.
+func deriveSecret(secret, label string) string {
+	key := make([]byte, 32)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte(label)), key)
+	return hex.EncodeToString(key)
+}
+
 func EnvInit() {
+	readSettings()
+
+	err := validateSettings(Settings)
+	if err != nil {
+		log.Println("Error loading .env variables")
+		log.Fatal(err.Error())
+	}
+}
+
+// readSettings fills Settings from the environment, applying the defaults of optional variables.
+func readSettings() {
 	Settings.PostgresDsn = os.Getenv("POSTGRES_DSN")
 	Settings.Port = os.Getenv("PORT")
 	Settings.JwtSecret = os.Getenv("JWT_SECRET")
@@ -40,6 +136,79 @@ func EnvInit() {
 	Settings.MailPassword = os.Getenv("MAIL_PASSWORD")
 	Settings.MailFrom = os.Getenv("MAIL_FROM")
 	Settings.Environment = os.Getenv("ENVIRONMENT")
+	Settings.AppBaseUrl = getEnvDefault("APP_BASE_URL", "http://localhost:"+Settings.Port)
+	//jwt lifetimes and the iss/aud claims tokens are issued and checked with
+	Settings.AccessTokenExpiry, _ = time.ParseDuration(getEnvDefault("ACCESS_TOKEN_EXPIRY", "24h"))
+	Settings.RefreshTokenExpiry, _ = time.ParseDuration(getEnvDefault("REFRESH_TOKEN_EXPIRY", "48h"))
+	Settings.TokenIssuer = getEnvDefault("TOKEN_ISSUER", "lightRoom")
+	Settings.TokenAudience = getEnvDefault("TOKEN_AUDIENCE", "lightRoom-api")
+	//two-factor, the issuer is the name authenticator apps show
+	Settings.TotpIssuer = getEnvDefault("TOTP_ISSUER", "LightRoom")
+	Settings.MfaChallengeExpiry, _ = time.ParseDuration(getEnvDefault("MFA_CHALLENGE_EXPIRY", "5m"))
+	//passkeys, the RP ID is the domain credentials are bound to and origins are comma separated
+	Settings.WebAuthnRPID = getEnvDefault("WEBAUTHN_RP_ID", "localhost")
+	Settings.WebAuthnRPDisplayName = getEnvDefault("WEBAUTHN_RP_DISPLAY_NAME", "LightRoom")
+	Settings.WebAuthnRPOrigins = getEnvDefault("WEBAUTHN_RP_ORIGINS", "http://localhost:"+Settings.Port)
+	//links in emails point at the frontend, which hands the token to the API
+	Settings.FrontendBaseUrl = getEnvDefault("FRONTEND_BASE_URL", Settings.AppBaseUrl)
+	Settings.VerificationTokenExpiry, _ = time.ParseDuration(getEnvDefault("VERIFICATION_TOKEN_EXPIRY", "24h"))
+	Settings.PasswordResetTokenExpiry, _ = time.ParseDuration(getEnvDefault("PASSWORD_RESET_TOKEN_EXPIRY", "15m"))
+	Settings.VerificationResendLimit, _ = strconv.ParseInt(getEnvDefault("VERIFICATION_RESEND_HOURLY_LIMIT", "3"), 10, 64)
+	Settings.MagicLinkExpiry, _ = time.ParseDuration(getEnvDefault("MAGIC_LINK_EXPIRY", "15m"))
+	Settings.MagicLinkHourlyLimit, _ = strconv.ParseInt(getEnvDefault("MAGIC_LINK_HOURLY_LIMIT", "5"), 10, 64)
+	//failed logins, backoff doubles from the base after a few misses until the lockout
+	Settings.LoginBackoffAfter, _ = strconv.ParseInt(getEnvDefault("LOGIN_BACKOFF_AFTER", "3"), 10, 64)
+	Settings.LoginBackoffBase, _ = time.ParseDuration(getEnvDefault("LOGIN_BACKOFF_BASE", "2s"))
+	Settings.LoginLockoutAttempts, _ = strconv.ParseInt(getEnvDefault("LOGIN_LOCKOUT_ATTEMPTS", "10"), 10, 64)
+	Settings.LoginLockoutDuration, _ = time.ParseDuration(getEnvDefault("LOGIN_LOCKOUT_DURATION", "15m"))
+	Settings.LoginIPLockoutAttempts, _ = strconv.ParseInt(getEnvDefault("LOGIN_IP_LOCKOUT_ATTEMPTS", "50"), 10, 64)
+	Settings.LoginFailureWindow, _ = time.ParseDuration(getEnvDefault("LOGIN_FAILURE_WINDOW", "1h"))
+	//password hashing, memory is in KiB, stored hashes are upgraded on login when these change
+	Settings.PasswordHashAlgorithm = getEnvDefault("PASSWORD_HASH_ALGORITHM", "argon2id")
+	Settings.Argon2Memory, _ = strconv.Atoi(getEnvDefault("ARGON2_MEMORY", "65536"))
+	Settings.Argon2Iterations, _ = strconv.Atoi(getEnvDefault("ARGON2_ITERATIONS", "3"))
+	Settings.Argon2Parallelism, _ = strconv.Atoi(getEnvDefault("ARGON2_PARALLELISM", "2"))
+	Settings.BcryptCost, _ = strconv.Atoi(getEnvDefault("BCRYPT_COST", "12"))
+	//new passwords, the filter is built from a SHA-1 breached password list by cmd/breachfilter
+	Settings.PasswordMinLength, _ = strconv.Atoi(getEnvDefault("PASSWORD_MIN_LENGTH", "8"))
+	Settings.BreachedPasswordFilter = os.Getenv("BREACHED_PASSWORD_FILTER")
+	//deleted accounts can be restored until the grace period ends, exports are kept for the retention
+	Settings.AccountDeletionGrace, _ = time.ParseDuration(getEnvDefault("ACCOUNT_DELETION_GRACE", "336h"))
+	Settings.DataExportRetention, _ = time.ParseDuration(getEnvDefault("DATA_EXPORT_RETENTION", "168h"))
+	//social login, a provider is enabled by setting its client id
+	Settings.OAuthCallbackBaseUrl = getEnvDefault("OAUTH_CALLBACK_BASE_URL", Settings.AppBaseUrl+"/api/v1/auth/oauth")
+	Settings.GoogleClientID = os.Getenv("GOOGLE_CLIENT_ID")
+	Settings.GoogleClientSecret = os.Getenv("GOOGLE_CLIENT_SECRET")
+	Settings.GithubClientID = os.Getenv("GITHUB_CLIENT_ID")
+	Settings.GithubClientSecret = os.Getenv("GITHUB_CLIENT_SECRET")
+	Settings.OidcProviderName = getEnvDefault("OIDC_PROVIDER_NAME", "oidc")
+	Settings.OidcIssuerUrl = os.Getenv("OIDC_ISSUER_URL")
+	Settings.OidcClientID = os.Getenv("OIDC_CLIENT_ID")
+	Settings.OidcClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	//storage backend, r2 unless told otherwise
+	Settings.StorageBackend = getEnvDefault("STORAGE_BACKEND", "r2")
+	Settings.LocalStorageDir = getEnvDefault("LOCAL_STORAGE_DIR", "uploads")
+	Settings.LocalStorageBaseUrl = getEnvDefault("LOCAL_STORAGE_BASE_URL", "http://localhost:"+Settings.Port+"/files")
+	//image renditions as name:longest-edge pairs
+	Settings.RenditionSizes = getEnvDefault("RENDITION_SIZES", "thumb:320,medium:960,large:1920")
+	//paywalled originals live below this prefix and previews get a watermark
+	Settings.PrivateStoragePrefix = getEnvDefault("PRIVATE_STORAGE_PREFIX", "private")
+	Settings.StorageSigningSecret = os.Getenv("STORAGE_SIGNING_SECRET")
+	if Settings.StorageSigningSecret == "" {
+		Settings.StorageSigningSecret = deriveSecret(Settings.JwtSecret, "lightroom storage url signing")
+	}
+	Settings.DownloadUrlExpiry, _ = time.ParseDuration(getEnvDefault("DOWNLOAD_URL_EXPIRY", "5m"))
+	Settings.WatermarkText = getEnvDefault("WATERMARK_TEXT", "LightRoom")
+	Settings.WatermarkLogoPath = os.Getenv("WATERMARK_LOGO_PATH")
+	Settings.WatermarkOpacity, _ = strconv.Atoi(getEnvDefault("WATERMARK_OPACITY", "40"))
+	Settings.WatermarkPreviewSize = getEnvDefault("WATERMARK_PREVIEW_SIZE", "medium")
+	//payments, the fake provider settles checkouts locally and is refused outside ENVIRONMENT=local
+	Settings.PaymentProvider = os.Getenv("PAYMENT_PROVIDER")
+	Settings.StripeSecretKey = os.Getenv("STRIPE_SECRET_KEY")
+	Settings.StripeWebhookSecret = os.Getenv("STRIPE_WEBHOOK_SECRET")
+	Settings.Currency = getEnvDefault("CURRENCY", "usd")
+	Settings.CheckoutSuccessUrl = getEnvDefault("CHECKOUT_SUCCESS_URL", Settings.AppBaseUrl+"/checkout/success")
+	Settings.CheckoutCancelUrl = getEnvDefault("CHECKOUT_CANCEL_URL", Settings.AppBaseUrl+"/checkout/cancel")
 	//cloudflare r2 bucket
 
 	Settings.CloudFlareBucket = os.Getenv("CLOUDFLARE_BUCKET")
@@ -48,14 +217,16 @@ func EnvInit() {
 	Settings.CloudFlareAccountID = os.Getenv("CLOUDFLARE_ACCOUNT_ID")
 	Settings.CloudFlareAccessKeyID = os.Getenv("CLOUDFLARE_ACCESS_KEY_ID")
 	Settings.CloudFlareCdnUrl = os.Getenv("CLOUDFLARE_CDN_URL")
+}
 
+// validateSettings checks settings against their tags and the rules that span several of them.
+func validateSettings(settings EnvSetting) error {
 	validate = validator.New()
-	err := validate.Struct(Settings)
-
-	if err != nil {
-		validationError := err.(validator.ValidationErrors)
-		log.Println("Error loading .env variables")
-		log.Fatal(validationError.Error())
+	if err := validate.Struct(settings); err != nil {
+		return err
 	}
-
+	if settings.PaymentProvider == "fake" && settings.Environment != "local" {
+		return errFakePayments
+	}
+	return nil
 }
@@ -2,11 +2,26 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 )
 
+// TokenGenerator returns the tokens mailed out for account verification and password resets,
+// 32 random bytes so they cannot be guessed or enumerated.
 func TokenGenerator() string {
-	b := make([]byte, 4)
+	return SecureToken(32)
+}
+
+// SecureToken returns a URL safe token carrying size bytes of randomness.
+func SecureToken(size int) string {
+	b := make([]byte, size)
 	rand.Read(b)
 	return base64.RawURLEncoding.EncodeToString(b)
 }
+
+// HashToken is how single-use tokens are stored, so a leaked Redis dump holds no usable links.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA1 vectors of RFC 6238 appendix B, cut to the six digits we use.
+func TestTotpCodeMatchesRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := []struct {
+		unixTime int64
+		code     string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, vector := range vectors {
+		if code := totpCode(key, vector.unixTime/totpPeriod); code != vector.code {
+			t.Errorf("totpCode at %d = %s, want %s", vector.unixTime, code, vector.code)
+		}
+	}
+}
+
+func TestValidateTotp(t *testing.T) {
+	secret := GenerateTotpSecret()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("GenerateTotpSecret returned %q, want 20 base32 bytes: %v", secret, err)
+	}
+	now := time.Now().Unix() / totpPeriod
+
+	step, ok := ValidateTotp(secret, totpCode(key, now), 0)
+	if !ok || step != now {
+		t.Fatalf("ValidateTotp rejected the current code: step %d, ok %v", step, ok)
+	}
+	if _, ok = ValidateTotp(strings.ToLower(secret), totpCode(key, now), 0); !ok {
+		t.Error("ValidateTotp rejected a lower case secret")
+	}
+	if _, ok = ValidateTotp(secret, totpCode(key, now-1), 0); !ok {
+		t.Error("ValidateTotp rejected the previous period's code")
+	}
+	if _, ok = ValidateTotp(secret, totpCode(key, now-3), 0); ok {
+		t.Error("ValidateTotp accepted a code from outside the skew")
+	}
+	if _, ok = ValidateTotp(secret, "12345", 0); ok {
+		t.Error("ValidateTotp accepted a code of the wrong length")
+	}
+	if _, ok = ValidateTotp("not base32!", totpCode(key, now), 0); ok {
+		t.Error("ValidateTotp accepted an undecodable secret")
+	}
+}
+
+func TestValidateTotpRefusesReplay(t *testing.T) {
+	secret := GenerateTotpSecret()
+	key, _ := totpEncoding.DecodeString(secret)
+	now := time.Now().Unix() / totpPeriod
+
+	step, ok := ValidateTotp(secret, totpCode(key, now), 0)
+	if !ok {
+		t.Fatal("ValidateTotp rejected the current code")
+	}
+	if _, ok = ValidateTotp(secret, totpCode(key, now), step); ok {
+		t.Error("ValidateTotp accepted the same code twice")
+	}
+	// An older code that was never used is refused once a later step has been.
+	if _, ok = ValidateTotp(secret, totpCode(key, now-1), step); ok {
+		t.Error("ValidateTotp accepted a code from before the last used step")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := GenerateRecoveryCodes()
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("GenerateRecoveryCodes returned %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("recovery code %q is not shaped xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("recovery code %q was generated twice", code)
+		}
+		seen[code] = true
+	}
+
+	if HashRecoveryCode(" ABCDE-12345 ") != HashRecoveryCode("abcde12345") {
+		t.Error("HashRecoveryCode does not ignore case, dashes and surrounding space")
+	}
+	if HashRecoveryCode("abcde-12345") == HashRecoveryCode("abcde-12346") {
+		t.Error("HashRecoveryCode gave two codes the same hash")
+	}
+}